@@ -0,0 +1,186 @@
+package mongodm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Driver selects the database adapter a connection is opened with (see: Config.Driver).
+type Driver string
+
+const (
+	DriverMgo    Driver = "mgo"    // gopkg.in/mgo.v2 (default)
+	DriverMongo  Driver = "mongo"  // go.mongodb.org/mongo-driver
+	DriverMemory Driver = "memory" // in-memory storage without database server, e.g. for unit tests
+)
+
+/*
+Backend is the storage layer underneath Connection, Model and Query. The ODM never talks to a database adapter directly,
+instead each registered model gets a BackendCollection from the backend of its connection.
+
+All documents, filters and selectors are passed as values which can be serialized with gopkg.in/mgo.v2/bson (e.g. bson.M or
+IDocumentBase types). Results are returned as bson.Raw documents, so the ODM decodes them the same way for every backend.
+*/
+type Backend interface {
+	/*
+	Collection returns the collection with the given name. An empty database name selects the default database of the connection.
+	If options are given, all operations of the collection use the mode and write concern instead of the connection settings.
+	*/
+	Collection(database string, name string, options *SessionOptions) BackendCollection
+
+	//Ping checks if the database server is reachable
+	Ping(ctx context.Context) error
+
+	//Refresh discards broken connections, so the next operation connects again, and checks the connection
+	Refresh(ctx context.Context) error
+
+	//IsNetworkError reports if an error of an operation was caused by a lost connection to the database server
+	IsNetworkError(err error) bool
+
+	//Close releases all resources of the backend
+	Close()
+}
+
+/*
+BackendCollection implements the collection operations the ODM needs. Each method must stop as soon as the given context
+is done and return the context error. Duplicate key errors must be reported as *DuplicateError.
+*/
+type BackendCollection interface {
+	Name() string
+
+	//Find returns all documents which match the filter. An empty result is no error.
+	Find(ctx context.Context, filter interface{}, options *FindOptions) ([]bson.Raw, error)
+	Count(ctx context.Context, filter interface{}) (int, error)
+
+	Insert(ctx context.Context, document interface{}) error
+	UpsertId(ctx context.Context, id interface{}, document interface{}) error
+
+	//Replace replaces the first document which matches the filter (keeping its id) and reports whether one matched
+	Replace(ctx context.Context, filter interface{}, document interface{}) (bool, error)
+
+	//Update applies the update operators (e.g. $set) to the first document which matches the filter and reports whether one matched
+	Update(ctx context.Context, filter interface{}, update interface{}) (bool, error)
+
+	//UpdateAll applies the update operators to all documents which match the filter and returns their number
+	UpdateAll(ctx context.Context, filter interface{}, update interface{}) (int, error)
+
+	//FindAndUpdate applies the update operators to the first matching document and returns it in its updated form
+	FindAndUpdate(ctx context.Context, filter interface{}, update interface{}) (bson.Raw, bool, error)
+
+	/*
+	Bulk sends the write operations with as few requests as possible. Ordered operations are executed in order and stop at
+	the first failed operation, otherwise all operations are attempted. Errors of single operations are reported by their
+	index in the result, the error is only returned if the bulk operation failed as a whole (e.g. the context is done).
+	*/
+	Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error)
+
+	//Remove deletes all documents which match the filter and returns their number
+	Remove(ctx context.Context, filter interface{}) (int, error)
+
+	EnsureIndex(ctx context.Context, index Index) error
+
+	//Indexes returns all indexes of the collection (including "_id_"). A collection which does not exist has no indexes.
+	Indexes(ctx context.Context) ([]Index, error)
+	DropIndex(ctx context.Context, name string) error
+
+	//SetValidator sets the validator of the collection (collMod), a collection which does not exist yet is created with it
+	SetValidator(ctx context.Context, validator bson.M, level ValidationLevel, action ValidationAction) error
+}
+
+//FindOptions describes how the result of a find operation is shaped.
+type FindOptions struct {
+	Selector interface{} // projection, see: http://godoc.org/labix.org/v2/mgo#Query.Select
+	Sort     []string    // field names, prefixed with "-" for descending order
+	Skip     int
+	Limit    int
+}
+
+//BulkOperation is one write of BackendCollection.Bulk: an insert, or an update of the first document which matches the filter
+type BulkOperation struct {
+	Insert  interface{} // document to insert, the other fields are ignored
+	Filter  interface{}
+	Update  interface{} // update operators (e.g. $set), or the replacement document if Replace is set
+	Replace bool
+}
+
+//BulkResult describes the outcome of BackendCollection.Bulk
+type BulkResult struct {
+	Matched int           // number of documents which matched the filters of updates
+	Errors  map[int]error // errors of single operations by their index, e.g. *DuplicateError
+}
+
+//Index describes an index of a collection, see: http://godoc.org/labix.org/v2/mgo#Index
+type Index struct {
+	Name        string        // default name is generated from the key, e.g. "name_1_createdAt_-1"
+	Key         []string      // field names, prefixed with "-" for descending order
+	Unique      bool          // prevent two documents from having the same index key
+	Sparse      bool          // only index documents containing the key fields
+	ExpireAfter time.Duration // remove documents after this duration (ttl index on a single time field)
+}
+
+//indexField strips the order prefix of an index or sort key
+func indexField(key string) string {
+
+	return strings.TrimLeft(key, "+-")
+}
+
+//indexName returns the default name of an index like mongodb (e.g. "name_1_createdAt_-1")
+func indexName(keys []string) string {
+
+	parts := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+
+		order := "1"
+
+		if strings.HasPrefix(key, "-") {
+			order = "-1"
+		}
+
+		parts = append(parts, indexField(key)+"_"+order)
+	}
+
+	return strings.Join(parts, "_")
+}
+
+//validatorCommand returns a create or collMod command which sets the validator of a collection
+func validatorCommand(command string, collection string, validator bson.M, level ValidationLevel, action ValidationAction) bson.D {
+
+	return bson.D{
+		{Name: command, Value: collection},
+		{Name: "validator", Value: validator},
+		{Name: "validationLevel", Value: string(level)},
+		{Name: "validationAction", Value: string(action)},
+	}
+}
+
+//rawDocument serializes a document with the mgo bson package and wraps it as raw document
+func rawDocument(document interface{}) (bson.Raw, error) {
+
+	if document == nil {
+		document = bson.M{}
+	}
+
+	data, err := bson.Marshal(document)
+
+	if err != nil {
+		return bson.Raw{}, err
+	}
+
+	return bson.Raw{Kind: 0x03, Data: data}, nil
+}
+
+//documentMap decodes a raw document as bson.M (embedded documents as bson.M, arrays as []interface{})
+func documentMap(raw bson.Raw) (bson.M, error) {
+
+	document := bson.M{}
+
+	if err := raw.Unmarshal(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
@@ -0,0 +1,749 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+memoryBackend implements the Backend interface without any database server. All documents are kept in memory and
+get lost when the connection is closed. It is meant for unit tests, see: Config.Driver (DriverMemory).
+
+Filters, sorting, skip/limit and selectors follow the mongodb semantics for the commonly used query operators
+($eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $regex, $size, $all, $elemMatch, $not, $and, $or, $nor).
+Unique indexes and collection validators (see: func (*Model) ApplyValidator) are enforced on insert and upsert.
+*/
+type memoryBackend struct {
+	mutex     sync.RWMutex
+	database  string
+	databases map[string]map[string]*memoryStore
+}
+
+type memoryStore struct {
+	documents []bson.M
+	indexes   []Index
+
+	validator        bson.M
+	validationLevel  ValidationLevel
+	validationAction ValidationAction
+}
+
+type memoryCollection struct {
+	backend  *memoryBackend
+	database string
+	name     string
+}
+
+func newMemoryBackend(config *Config) (*memoryBackend, error) {
+
+	database := config.DatabaseName
+
+	if len(config.URI) > 0 {
+
+		uri, err := ParseURI(config.URI)
+
+		if err != nil {
+			return nil, err
+		}
+
+		database = uri.Database
+
+	} else if config.DialInfo != nil {
+		database = config.DialInfo.Database
+	}
+
+	if len(database) == 0 {
+		database = "test" // mgo default database
+	}
+
+	return &memoryBackend{
+		database:  database,
+		databases: make(map[string]map[string]*memoryStore),
+	}, nil
+}
+
+//Collection ignores the session options, the memory driver is always consistent
+func (self *memoryBackend) Collection(database string, name string, options *SessionOptions) BackendCollection {
+
+	if len(database) == 0 {
+		database = self.database
+	}
+
+	return &memoryCollection{self, database, name}
+}
+
+func (self *memoryBackend) Close() {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.databases = make(map[string]map[string]*memoryStore)
+}
+
+func (self *memoryBackend) Ping(ctx context.Context) error {
+
+	return ctx.Err()
+}
+
+func (self *memoryBackend) Refresh(ctx context.Context) error {
+
+	return ctx.Err()
+}
+
+//IsNetworkError always returns false, the memory driver has no network connection
+func (self *memoryBackend) IsNetworkError(err error) bool {
+
+	return false
+}
+
+//store returns the documents of the collection, create must be set for write operations (expects the write lock)
+func (self *memoryCollection) store(create bool) *memoryStore {
+
+	collections, ok := self.backend.databases[self.database]
+
+	if !ok {
+
+		if !create {
+			return &memoryStore{}
+		}
+
+		collections = make(map[string]*memoryStore)
+		self.backend.databases[self.database] = collections
+	}
+
+	store, ok := collections[self.name]
+
+	if !ok {
+
+		store = &memoryStore{}
+
+		if create {
+			collections[self.name] = store
+		}
+	}
+
+	return store
+}
+
+func (self *memoryCollection) Name() string {
+
+	return self.name
+}
+
+func (self *memoryCollection) Find(ctx context.Context, filter interface{}, options *FindOptions) ([]bson.Raw, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query, err := memoryFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = &FindOptions{}
+	}
+
+	self.backend.mutex.RLock()
+	defer self.backend.mutex.RUnlock()
+
+	matches := []bson.M{}
+
+	for _, document := range self.store(false).documents {
+
+		match, err := matchDocument(document, query)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if match {
+			matches = append(matches, document)
+		}
+	}
+
+	if len(options.Sort) > 0 {
+		sortDocuments(matches, options.Sort)
+	}
+
+	if options.Skip > 0 {
+
+		if options.Skip >= len(matches) {
+			matches = matches[:0]
+		} else {
+			matches = matches[options.Skip:]
+		}
+	}
+
+	limit := options.Limit
+
+	if limit < 0 {
+		limit = -limit
+	}
+
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+
+	var selector bson.M
+
+	if options.Selector != nil {
+
+		selector, err = memoryFilter(options.Selector)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	raws := make([]bson.Raw, 0, len(matches))
+
+	for _, document := range matches {
+
+		if selector != nil {
+			document = projectDocument(document, selector)
+		}
+
+		raw, err := rawDocument(document)
+
+		if err != nil {
+			return nil, err
+		}
+
+		raws = append(raws, raw)
+	}
+
+	return raws, nil
+}
+
+func (self *memoryCollection) Count(ctx context.Context, filter interface{}) (int, error) {
+
+	raws, err := self.Find(ctx, filter, nil)
+
+	return len(raws), err
+}
+
+func (self *memoryCollection) Insert(ctx context.Context, document interface{}) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stored, err := memoryFilter(document)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := stored["_id"]; !ok {
+		stored["_id"] = bson.NewObjectId()
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(true)
+
+	if err := store.checkValidator(stored, -1); err != nil {
+		return err
+	}
+
+	if err := store.checkUnique(stored, -1); err != nil {
+		return err
+	}
+
+	store.documents = append(store.documents, stored)
+
+	return nil
+}
+
+func (self *memoryCollection) UpsertId(ctx context.Context, id interface{}, document interface{}) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stored, err := memoryFilter(document)
+
+	if err != nil {
+		return err
+	}
+
+	normalized, err := memoryFilter(bson.M{"_id": id})
+
+	if err != nil {
+		return err
+	}
+
+	stored["_id"] = normalized["_id"]
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(true)
+	position := store.indexOf(stored["_id"])
+
+	if err := store.checkValidator(stored, position); err != nil {
+		return err
+	}
+
+	if err := store.checkUnique(stored, position); err != nil {
+		return err
+	}
+
+	if position < 0 {
+		store.documents = append(store.documents, stored)
+	} else {
+		store.documents[position] = stored
+	}
+
+	return nil
+}
+
+func (self *memoryCollection) Replace(ctx context.Context, filter interface{}, document interface{}) (bool, error) {
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	query, err := memoryFilter(filter)
+
+	if err != nil {
+		return false, err
+	}
+
+	stored, err := memoryFilter(document)
+
+	if err != nil {
+		return false, err
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(false)
+
+	for position, current := range store.documents {
+
+		match, err := matchDocument(current, query)
+
+		if err != nil {
+			return false, err
+		} else if !match {
+			continue
+		}
+
+		stored["_id"] = current["_id"]
+
+		if err := store.checkValidator(stored, position); err != nil {
+			return false, err
+		}
+
+		if err := store.checkUnique(stored, position); err != nil {
+			return false, err
+		}
+
+		store.documents[position] = stored
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (self *memoryCollection) Update(ctx context.Context, filter interface{}, update interface{}) (bool, error) {
+
+	updated, err := self.update(ctx, filter, update, false)
+
+	return len(updated) > 0, err
+}
+
+func (self *memoryCollection) UpdateAll(ctx context.Context, filter interface{}, update interface{}) (int, error) {
+
+	updated, err := self.update(ctx, filter, update, true)
+
+	return len(updated), err
+}
+
+func (self *memoryCollection) FindAndUpdate(ctx context.Context, filter interface{}, update interface{}) (bson.Raw, bool, error) {
+
+	updated, err := self.update(ctx, filter, update, false)
+
+	if err != nil || len(updated) == 0 {
+		return bson.Raw{}, false, err
+	}
+
+	raw, err := rawDocument(updated[0])
+
+	return raw, err == nil, err
+}
+
+//update applies the update operators to the matching documents (only to the first one unless multiple is set) and returns them
+func (self *memoryCollection) update(ctx context.Context, filter interface{}, update interface{}, multiple bool) ([]bson.M, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query, err := memoryFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	operators, err := memoryFilter(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(false)
+	result := []bson.M{}
+
+	for position, current := range store.documents {
+
+		match, err := matchDocument(current, query)
+
+		if err != nil {
+			return result, err
+		} else if !match {
+			continue
+		}
+
+		//the stored document is only replaced if the update succeeded
+		updated, err := memoryFilter(current)
+
+		if err != nil {
+			return result, err
+		}
+
+		if err := applyUpdate(updated, operators); err != nil {
+			return result, err
+		}
+
+		if err := store.checkValidator(updated, position); err != nil {
+			return result, err
+		}
+
+		if err := store.checkUnique(updated, position); err != nil {
+			return result, err
+		}
+
+		store.documents[position] = updated
+		result = append(result, updated)
+
+		if !multiple {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+//Bulk executes the operations one after another, like the server does for ordered bulk operations
+func (self *memoryCollection) Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error) {
+
+	result := &BulkResult{Errors: map[int]error{}}
+
+	for index, operation := range operations {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var matched bool
+		var err error
+
+		if operation.Insert != nil {
+			err = self.Insert(ctx, operation.Insert)
+		} else if operation.Replace {
+			matched, err = self.Replace(ctx, operation.Filter, operation.Update)
+		} else {
+			matched, err = self.Update(ctx, operation.Filter, operation.Update)
+		}
+
+		if matched {
+			result.Matched++
+		}
+
+		if err != nil {
+
+			result.Errors[index] = err
+
+			if ordered {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (self *memoryCollection) Remove(ctx context.Context, filter interface{}) (int, error) {
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	query, err := memoryFilter(filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(false)
+	kept := store.documents[:0]
+
+	for _, document := range store.documents {
+
+		match, err := matchDocument(document, query)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if !match {
+			kept = append(kept, document)
+		}
+	}
+
+	removed := len(store.documents) - len(kept)
+
+	// clear the tail, so removed documents can be garbage collected
+	for position := len(kept); position < len(store.documents); position++ {
+		store.documents[position] = nil
+	}
+
+	store.documents = kept
+
+	return removed, nil
+}
+
+func (self *memoryCollection) EnsureIndex(ctx context.Context, index Index) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(index.Key) == 0 {
+		return fmt.Errorf("DB: Invalid index key for collection '%v'", self.name)
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(true)
+
+	if len(index.Name) == 0 {
+		index.Name = indexName(index.Key)
+	}
+
+	for position, existing := range store.indexes {
+
+		if existing.Name == index.Name {
+			store.indexes[position] = index
+			return nil
+		}
+	}
+
+	// like mongodb: a unique index can not be created on duplicated values
+	if index.Unique {
+
+		probe := &memoryStore{indexes: []Index{index}}
+
+		for _, document := range store.documents {
+
+			if err := probe.checkUnique(document, -1); err != nil {
+				return err
+			}
+
+			probe.documents = append(probe.documents, document)
+		}
+	}
+
+	store.indexes = append(store.indexes, index)
+
+	return nil
+}
+
+func (self *memoryCollection) Indexes(ctx context.Context) ([]Index, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	self.backend.mutex.RLock()
+	defer self.backend.mutex.RUnlock()
+
+	if _, ok := self.backend.databases[self.database][self.name]; !ok {
+		return nil, nil
+	}
+
+	// like mongodb: each collection has an index on the id
+	indexes := []Index{{Name: "_id_", Key: []string{"_id"}}}
+
+	return append(indexes, self.store(false).indexes...), nil
+}
+
+func (self *memoryCollection) DropIndex(ctx context.Context, name string) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if name == "_id_" {
+		return fmt.Errorf("DB: The index '_id_' of collection '%v' can not be dropped", self.name)
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(false)
+
+	for position, existing := range store.indexes {
+
+		if existing.Name == name {
+			store.indexes = append(store.indexes[:position], store.indexes[position+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("DB: Index '%v' not found in collection '%v'", name, self.name)
+}
+
+func (self *memoryCollection) SetValidator(ctx context.Context, validator bson.M, level ValidationLevel, action ValidationAction) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// normalize the validator like a stored document, e.g. lists become []interface{}
+	normalized, err := memoryFilter(validator)
+
+	if err != nil {
+		return err
+	}
+
+	self.backend.mutex.Lock()
+	defer self.backend.mutex.Unlock()
+
+	store := self.store(true)
+
+	store.validator = normalized
+	store.validationLevel = level
+	store.validationAction = action
+
+	return nil
+}
+
+//indexOf returns the position of the document with the given id or -1
+func (self *memoryStore) indexOf(id interface{}) int {
+
+	for position, document := range self.documents {
+
+		if compareValues(document["_id"], id) == 0 {
+			return position
+		}
+	}
+
+	return -1
+}
+
+/*
+checkValidator returns a *ValidationError if the document does not pass the validator of the collection (skip is the position
+of the replaced document). Like mongodb, the moderate level does not check updates of documents which were invalid before.
+*/
+func (self *memoryStore) checkValidator(document bson.M, skip int) error {
+
+	if len(self.validator) == 0 || self.validationLevel == ValidationLevelOff || self.validationAction == ValidationActionWarn {
+		return nil
+	}
+
+	if self.validationLevel == ValidationLevelModerate && skip >= 0 {
+
+		if valid, err := memoryValidate(self.documents[skip], self.validator); err != nil || !valid {
+			return err
+		}
+	}
+
+	valid, err := memoryValidate(document, self.validator)
+
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return &ValidationError{&QueryError{"DB: Document failed validation"}, nil}
+	}
+
+	return nil
+}
+
+//checkUnique returns a *DuplicateError if the document violates the id or a unique index (skip is the position of the replaced document)
+func (self *memoryStore) checkUnique(document bson.M, skip int) error {
+
+	for position, existing := range self.documents {
+
+		if position == skip {
+			continue
+		}
+
+		if compareValues(existing["_id"], document["_id"]) == 0 {
+			return &DuplicateError{&QueryError{"Duplicate key"}}
+		}
+
+		for _, index := range self.indexes {
+
+			if !index.Unique {
+				continue
+			}
+
+			if index.Sparse && !hasIndexKey(document, index) {
+				continue
+			}
+
+			equal := true
+
+			for _, key := range index.Key {
+
+				field := indexField(key)
+				value, _ := lookupPath(document, field)
+				existingValue, _ := lookupPath(existing, field)
+
+				if compareValues(value, existingValue) != 0 {
+					equal = false
+					break
+				}
+			}
+
+			if equal {
+				return &DuplicateError{&QueryError{fmt.Sprintf("Duplicate key for index '%v'", index.Name)}}
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasIndexKey(document bson.M, index Index) bool {
+
+	for _, key := range index.Key {
+
+		if _, ok := lookupPath(document, indexField(key)); ok {
+			return true
+		}
+	}
+
+	return false
+}
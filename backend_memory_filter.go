@@ -0,0 +1,748 @@
+package mongodm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+This file contains the query engine of the memory driver. All values are normalized with the mgo bson package first
+(see memoryFilter), so documents and filters only consist of bson.M, []interface{} and the bson scalar types.
+*/
+
+//memoryFilter serializes a value and decodes it as bson.M, which also creates a deep copy
+func memoryFilter(value interface{}) (bson.M, error) {
+
+	raw, err := rawDocument(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	document := bson.M{}
+
+	if err := raw.Unmarshal(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+//matchDocument reports whether the document matches all conditions of the filter
+func matchDocument(document bson.M, filter bson.M) (bool, error) {
+
+	for key, condition := range filter {
+
+		var match bool
+		var err error
+
+		switch key {
+
+		case "$and", "$or", "$nor":
+
+			match, err = matchLogical(document, key, condition)
+
+		default:
+
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("DB: Operator '%v' is not supported by the memory driver", key)
+			}
+
+			match, err = matchField(resolvePath(document, key), condition)
+		}
+
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchLogical(document bson.M, operator string, condition interface{}) (bool, error) {
+
+	filters, ok := condition.([]interface{})
+
+	if !ok || len(filters) == 0 {
+		return false, fmt.Errorf("DB: Operator '%v' expects a non empty array", operator)
+	}
+
+	for _, element := range filters {
+
+		filter, ok := element.(bson.M)
+
+		if !ok {
+			return false, fmt.Errorf("DB: Operator '%v' expects an array of documents", operator)
+		}
+
+		match, err := matchDocument(document, filter)
+
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case operator == "$and" && !match:
+			return false, nil
+		case operator == "$or" && match:
+			return true, nil
+		case operator == "$nor" && match:
+			return false, nil
+		}
+	}
+
+	return operator != "$or", nil
+}
+
+//fieldValues contains all values a path resolves to (array elements are expanded like in mongodb)
+type fieldValues struct {
+	values []interface{}
+	found  bool
+}
+
+func resolvePath(document bson.M, path string) fieldValues {
+
+	result := fieldValues{}
+
+	for _, value := range resolveValue(document, strings.Split(path, ".")) {
+
+		result.found = true
+		result.values = append(result.values, value)
+
+		if array, ok := value.([]interface{}); ok {
+			result.values = append(result.values, array...)
+		}
+	}
+
+	return result
+}
+
+func resolveValue(value interface{}, parts []string) []interface{} {
+
+	if len(parts) == 0 {
+		return []interface{}{value}
+	}
+
+	switch typed := value.(type) {
+
+	case bson.M:
+
+		if child, ok := typed[parts[0]]; ok {
+			return resolveValue(child, parts[1:])
+		}
+
+	case []interface{}:
+
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+
+			if index >= 0 && index < len(typed) {
+				return resolveValue(typed[index], parts[1:])
+			}
+
+			return nil
+		}
+
+		values := []interface{}{}
+
+		for _, element := range typed {
+
+			if _, ok := element.(bson.M); ok {
+				values = append(values, resolveValue(element, parts)...)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+//lookupPath returns the first value of a path (used for sorting, indexes and projections)
+func lookupPath(document bson.M, path string) (interface{}, bool) {
+
+	values := resolveValue(document, strings.Split(path, "."))
+
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return values[0], true
+}
+
+func isOperatorDocument(document bson.M) bool {
+
+	for key := range document {
+		return strings.HasPrefix(key, "$")
+	}
+
+	return false
+}
+
+func matchField(field fieldValues, condition interface{}) (bool, error) {
+
+	if operators, ok := condition.(bson.M); ok && isOperatorDocument(operators) {
+
+		for operator, argument := range operators {
+
+			match, err := matchOperator(field, operator, argument, operators)
+
+			if err != nil || !match {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+
+	if regex, ok := condition.(bson.RegEx); ok {
+		return matchRegex(field, regex)
+	}
+
+	return matchEqual(field, condition), nil
+}
+
+func matchEqual(field fieldValues, expected interface{}) bool {
+
+	if expected == nil && !field.found {
+		return true
+	}
+
+	for _, value := range field.values {
+
+		if compareValues(value, expected) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchOperator(field fieldValues, operator string, argument interface{}, operators bson.M) (bool, error) {
+
+	switch operator {
+
+	case "$eq":
+		return matchEqual(field, argument), nil
+
+	case "$ne":
+		return !matchEqual(field, argument), nil
+
+	case "$gt", "$gte", "$lt", "$lte":
+
+		for _, value := range field.values {
+
+			if typeOrder(value) != typeOrder(argument) {
+				continue
+			}
+
+			result := compareValues(value, argument)
+
+			if (operator == "$gt" && result > 0) || (operator == "$gte" && result >= 0) ||
+				(operator == "$lt" && result < 0) || (operator == "$lte" && result <= 0) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+
+	case "$in", "$nin":
+
+		candidates, ok := argument.([]interface{})
+
+		if !ok {
+			return false, fmt.Errorf("DB: Operator '%v' expects an array", operator)
+		}
+
+		match := false
+
+		for _, candidate := range candidates {
+
+			if regex, ok := candidate.(bson.RegEx); ok {
+
+				regexMatch, err := matchRegex(field, regex)
+
+				if err != nil {
+					return false, err
+				}
+
+				match = regexMatch
+
+			} else {
+				match = matchEqual(field, candidate)
+			}
+
+			if match {
+				break
+			}
+		}
+
+		return match == (operator == "$in"), nil
+
+	case "$exists":
+		return field.found == truthy(argument), nil
+
+	case "$regex":
+
+		regex := bson.RegEx{}
+
+		switch typed := argument.(type) {
+		case bson.RegEx:
+			regex = typed
+		case string:
+			regex.Pattern = typed
+		default:
+			return false, fmt.Errorf("DB: Operator '$regex' expects a string")
+		}
+
+		if options, ok := operators["$options"].(string); ok {
+			regex.Options = options
+		}
+
+		return matchRegex(field, regex)
+
+	case "$options":
+		return true, nil
+
+	case "$size":
+
+		for _, value := range field.values {
+
+			if array, ok := value.([]interface{}); ok && compareValues(len(array), argument) == 0 {
+				return true, nil
+			}
+		}
+
+		return false, nil
+
+	case "$all":
+
+		candidates, ok := argument.([]interface{})
+
+		if !ok {
+			return false, fmt.Errorf("DB: Operator '$all' expects an array")
+		}
+
+		for _, candidate := range candidates {
+
+			if !matchEqual(field, candidate) {
+				return false, nil
+			}
+		}
+
+		return len(candidates) > 0, nil
+
+	case "$elemMatch":
+
+		condition, ok := argument.(bson.M)
+
+		if !ok {
+			return false, fmt.Errorf("DB: Operator '$elemMatch' expects a document")
+		}
+
+		for _, value := range field.values {
+
+			array, ok := value.([]interface{})
+
+			if !ok {
+				continue
+			}
+
+			for _, element := range array {
+
+				var match bool
+				var err error
+
+				if isOperatorDocument(condition) {
+					match, err = matchField(fieldValues{[]interface{}{element}, true}, condition)
+				} else if document, ok := element.(bson.M); ok {
+					match, err = matchDocument(document, condition)
+				}
+
+				if err != nil {
+					return false, err
+				}
+
+				if match {
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+
+	case "$not":
+
+		match, err := matchField(field, argument)
+
+		return !match, err
+	}
+
+	return false, fmt.Errorf("DB: Operator '%v' is not supported by the memory driver", operator)
+}
+
+func matchRegex(field fieldValues, regex bson.RegEx) (bool, error) {
+
+	flags := ""
+
+	for _, option := range regex.Options {
+
+		if strings.ContainsRune("ims", option) {
+			flags += string(option)
+		}
+	}
+
+	pattern := regex.Pattern
+
+	if len(flags) > 0 {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, value := range field.values {
+
+		if text, ok := value.(string); ok && compiled.MatchString(text) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func truthy(value interface{}) bool {
+
+	switch typed := value.(type) {
+	case bool:
+		return typed
+	case nil:
+		return false
+	}
+
+	if number, ok := toFloat(value); ok {
+		return number != 0
+	}
+
+	return true
+}
+
+//typeOrder returns the position of the value type in the bson comparison order
+func typeOrder(value interface{}) int {
+
+	if value == bson.MinKey {
+		return 0
+	} else if value == bson.MaxKey {
+		return 12
+	}
+
+	switch value.(type) {
+	case nil:
+		return 1
+	case int, int32, int64, float32, float64:
+		return 2
+	case string, bson.Symbol:
+		return 3
+	case bson.M:
+		return 4
+	case []interface{}:
+		return 5
+	case []byte, bson.Binary:
+		return 6
+	case bson.ObjectId:
+		return 7
+	case bool:
+		return 8
+	case time.Time:
+		return 9
+	case bson.MongoTimestamp:
+		return 10
+	case bson.RegEx:
+		return 11
+	}
+
+	return 13
+}
+
+//binaryData returns the bytes of []byte and bson.Binary values, both are compared as binary data
+func binaryData(value interface{}) []byte {
+
+	if binary, ok := value.(bson.Binary); ok {
+		return binary.Data
+	}
+
+	data, _ := value.([]byte)
+
+	return data
+}
+
+func toFloat(value interface{}) (float64, bool) {
+
+	switch typed := value.(type) {
+	case int:
+		return float64(typed), true
+	case int32:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case float32:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	}
+
+	return 0, false
+}
+
+//compareValues compares two normalized bson values like mongodb does (-1, 0, 1)
+func compareValues(a interface{}, b interface{}) int {
+
+	orderA := typeOrder(a)
+	orderB := typeOrder(b)
+
+	if orderA != orderB {
+		return compareInts(orderA, orderB)
+	}
+
+	switch typedA := a.(type) {
+
+	case int, int32, int64, float32, float64:
+
+		numberA, _ := toFloat(a)
+		numberB, _ := toFloat(b)
+
+		if math.IsNaN(numberA) || math.IsNaN(numberB) {
+			return compareInts(boolInt(!math.IsNaN(numberA)), boolInt(!math.IsNaN(numberB)))
+		}
+
+		if numberA < numberB {
+			return -1
+		} else if numberA > numberB {
+			return 1
+		}
+
+		return 0
+
+	case string:
+		return strings.Compare(typedA, fmt.Sprint(b))
+
+	case bson.Symbol:
+		return strings.Compare(string(typedA), fmt.Sprint(b))
+
+	case bson.ObjectId:
+		return strings.Compare(string(typedA), string(b.(bson.ObjectId)))
+
+	case bool:
+		return compareInts(boolInt(typedA), boolInt(b.(bool)))
+
+	case time.Time:
+
+		typedB := b.(time.Time)
+
+		if typedA.Before(typedB) {
+			return -1
+		} else if typedA.After(typedB) {
+			return 1
+		}
+
+		return 0
+
+	case bson.MongoTimestamp:
+		return compareInts(int(typedA), int(b.(bson.MongoTimestamp)))
+
+	case []byte, bson.Binary:
+		return bytes.Compare(binaryData(a), binaryData(b))
+
+	case bson.RegEx:
+		return strings.Compare(typedA.Pattern+"/"+typedA.Options, b.(bson.RegEx).Pattern+"/"+b.(bson.RegEx).Options)
+
+	case []interface{}:
+
+		typedB := b.([]interface{})
+
+		for index := 0; index < len(typedA) && index < len(typedB); index++ {
+
+			if result := compareValues(typedA[index], typedB[index]); result != 0 {
+				return result
+			}
+		}
+
+		return compareInts(len(typedA), len(typedB))
+
+	case bson.M:
+
+		typedB := b.(bson.M)
+		keysA := sortedKeys(typedA)
+		keysB := sortedKeys(typedB)
+
+		for index := 0; index < len(keysA) && index < len(keysB); index++ {
+
+			if result := strings.Compare(keysA[index], keysB[index]); result != 0 {
+				return result
+			}
+
+			if result := compareValues(typedA[keysA[index]], typedB[keysB[index]]); result != 0 {
+				return result
+			}
+		}
+
+		return compareInts(len(keysA), len(keysB))
+	}
+
+	return 0
+}
+
+func compareInts(a int, b int) int {
+
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func boolInt(value bool) int {
+
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func sortedKeys(document bson.M) []string {
+
+	keys := make([]string, 0, len(document))
+
+	for key := range document {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+//sortDocuments orders documents by mgo sort fields (e.g. "-createdAt")
+func sortDocuments(documents []bson.M, fields []string) {
+
+	sort.SliceStable(documents, func(i int, j int) bool {
+
+		for _, field := range fields {
+
+			path := indexField(field)
+			valueA, _ := lookupPath(documents[i], path)
+			valueB, _ := lookupPath(documents[j], path)
+
+			result := compareValues(valueA, valueB)
+
+			if strings.HasPrefix(field, "-") {
+				result = -result
+			}
+
+			if result != 0 {
+				return result < 0
+			}
+		}
+
+		return false
+	})
+}
+
+//projectDocument applies a selector (e.g. bson.M{"firstname": 1}) to a document
+func projectDocument(document bson.M, selector bson.M) bson.M {
+
+	inclusion := false
+
+	for key, value := range selector {
+
+		if key != "_id" && truthy(value) {
+			inclusion = true
+		}
+	}
+
+	if !inclusion {
+
+		projected, _ := memoryFilter(document)
+
+		for key, value := range selector {
+
+			if !truthy(value) {
+				deletePath(projected, key)
+			}
+		}
+
+		return projected
+	}
+
+	projected := bson.M{}
+
+	if id, ok := selector["_id"]; !ok || truthy(id) {
+
+		if value, ok := document["_id"]; ok {
+			projected["_id"] = value
+		}
+	}
+
+	for key, value := range selector {
+
+		if key == "_id" || !truthy(value) {
+			continue
+		}
+
+		if fieldValue, ok := lookupPath(document, key); ok {
+			setPath(projected, key, fieldValue)
+		}
+	}
+
+	return projected
+}
+
+func setPath(document bson.M, path string, value interface{}) {
+
+	parts := strings.Split(path, ".")
+
+	for _, part := range parts[:len(parts)-1] {
+
+		child, ok := document[part].(bson.M)
+
+		if !ok {
+			child = bson.M{}
+			document[part] = child
+		}
+
+		document = child
+	}
+
+	document[parts[len(parts)-1]] = value
+}
+
+func deletePath(document bson.M, path string) {
+
+	parts := strings.Split(path, ".")
+
+	for _, part := range parts[:len(parts)-1] {
+
+		child, ok := document[part].(bson.M)
+
+		if !ok {
+			return
+		}
+
+		document = child
+	}
+
+	delete(document, parts[len(parts)-1])
+}
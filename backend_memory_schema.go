@@ -0,0 +1,217 @@
+package mongodm
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+memoryValidate checks a document against the validator of a collection. Like mongodb, the validator is a filter which
+may contain a $jsonSchema. The memory backend supports the $jsonSchema keywords the ODM generates (see: func (*Model) JSONSchema),
+namely bsonType, required, properties, items, minLength, maxLength, minItems, maxItems, pattern and anyOf.
+*/
+func memoryValidate(document bson.M, validator bson.M) (bool, error) {
+
+	filter := bson.M{}
+
+	for key, value := range validator {
+
+		if key != "$jsonSchema" {
+			filter[key] = value
+			continue
+		}
+
+		jsonSchema, ok := value.(bson.M)
+
+		if !ok {
+			return false, fmt.Errorf("DB: $jsonSchema must be a document")
+		}
+
+		if valid, err := matchJSONSchema(document, jsonSchema); !valid || err != nil {
+			return false, err
+		}
+	}
+
+	return matchDocument(document, filter)
+}
+
+//matchJSONSchema reports if a value matches a $jsonSchema, unknown keywords are ignored
+func matchJSONSchema(value interface{}, jsonSchema bson.M) (bool, error) {
+
+	if bsonType, ok := jsonSchema["bsonType"]; ok && !matchBsonType(value, bsonType) {
+		return false, nil
+	}
+
+	switch typedValue := value.(type) {
+	case bson.M:
+
+		if required, ok := jsonSchema["required"].([]interface{}); ok {
+
+			for _, key := range required {
+
+				if _, ok := typedValue[fmt.Sprint(key)]; !ok {
+					return false, nil
+				}
+			}
+		}
+
+		properties, _ := jsonSchema["properties"].(bson.M)
+
+		for key, propertySchema := range properties {
+
+			propertyValue, ok := typedValue[key]
+
+			if !ok {
+				continue
+			}
+
+			propertySchema, ok := propertySchema.(bson.M)
+
+			if !ok {
+				return false, fmt.Errorf("DB: $jsonSchema property '%v' must be a document", key)
+			}
+
+			if valid, err := matchJSONSchema(propertyValue, propertySchema); !valid || err != nil {
+				return false, err
+			}
+		}
+
+	case string:
+
+		length := utf8.RuneCountInString(typedValue)
+
+		if minLength, ok := jsonSchema["minLength"]; ok && length < schemaInt(minLength) {
+			return false, nil
+		}
+
+		if maxLength, ok := jsonSchema["maxLength"]; ok && length > schemaInt(maxLength) {
+			return false, nil
+		}
+
+		if pattern, ok := jsonSchema["pattern"].(string); ok {
+
+			expression, err := regexp.Compile(pattern)
+
+			if err != nil {
+				return false, err
+			}
+
+			if !expression.MatchString(typedValue) {
+				return false, nil
+			}
+		}
+
+	case []interface{}:
+
+		if minItems, ok := jsonSchema["minItems"]; ok && len(typedValue) < schemaInt(minItems) {
+			return false, nil
+		}
+
+		if maxItems, ok := jsonSchema["maxItems"]; ok && len(typedValue) > schemaInt(maxItems) {
+			return false, nil
+		}
+
+		if items, ok := jsonSchema["items"].(bson.M); ok {
+
+			for _, item := range typedValue {
+
+				if valid, err := matchJSONSchema(item, items); !valid || err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+
+	if anyOf, ok := jsonSchema["anyOf"].([]interface{}); ok {
+
+		for _, alternative := range anyOf {
+
+			alternativeSchema, ok := alternative.(bson.M)
+
+			if !ok {
+				return false, fmt.Errorf("DB: $jsonSchema anyOf must contain documents")
+			}
+
+			if valid, err := matchJSONSchema(value, alternativeSchema); valid || err != nil {
+				return valid, err
+			}
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+//matchBsonType reports if the value has one of the given bson types (a single name or a list of names)
+func matchBsonType(value interface{}, bsonType interface{}) bool {
+
+	names, ok := bsonType.([]interface{})
+
+	if !ok {
+		names = []interface{}{bsonType}
+	}
+
+	actual := memoryBsonType(value)
+
+	for _, name := range names {
+
+		if name == actual || (name == "number" && (actual == "int" || actual == "long" || actual == "double")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//memoryBsonType returns the bson type name of a value which was unmarshaled into a bson.M
+func memoryBsonType(value interface{}) string {
+
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int:
+		return "int" // mgo unmarshals int32 values as int
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case time.Time:
+		return "date"
+	case bson.ObjectId:
+		return "objectId"
+	case []interface{}:
+		return "array"
+	case bson.M:
+		return "object"
+	case []byte, bson.Binary:
+		return "binData"
+	case bson.RegEx:
+		return "regex"
+	}
+
+	return ""
+}
+
+//schemaInt converts a numeric $jsonSchema keyword
+func schemaInt(value interface{}) int {
+
+	switch number := value.(type) {
+	case int:
+		return number
+	case int64:
+		return int(number)
+	case float64:
+		return int(number)
+	}
+
+	return 0
+}
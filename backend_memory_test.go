@@ -0,0 +1,210 @@
+package mongodm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func memoryConnection(t *testing.T) *Connection {
+
+	var localMap map[string]map[string]string
+	json.Unmarshal(localsFile, &localMap)
+
+	db, err := Connect(&Config{
+		DatabaseName: DBName,
+		Driver:       DriverMemory,
+		Locals:       localMap["en-US"],
+	})
+
+	if err != nil {
+		t.Fatal("DB: Connection error", err)
+	}
+
+	db.Register(&TestModel{}, DBTestCollection)
+	db.Register(&TestRelationModel{}, DBTestRelCollection)
+
+	return db
+}
+
+func saveTestModel(t *testing.T, db *Connection, name string, number int) *TestModel {
+
+	testModel := &TestModel{}
+
+	db.Model("testmodel").New(testModel)
+
+	testModel.Name = name
+	testModel.Number = number
+	testModel.RequiredField = "Test"
+	testModel.Relation1N = []bson.ObjectId{}
+
+	if err := testModel.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	return testModel
+}
+
+func TestMemoryCreateAndPopulate(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+	TestRelation := db.Model("testrelationmodel")
+
+	testRelationModel := &TestRelationModel{}
+
+	TestRelation.New(testRelationModel)
+
+	testRelationModel.RelationName = "some relation"
+
+	if err := testRelationModel.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	testModel := &TestModel{}
+
+	Test.New(testModel)
+
+	testModel.Name = "Some test"
+	testModel.RequiredField = "Test"
+	testModel.Relation11 = testRelationModel
+	testModel.Relation1N = []*TestRelationModel{testRelationModel, testRelationModel}
+	testModel.TestEmbed = &TestEmbedModel{Value: "embedded"}
+
+	if err := testModel.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	found := &TestModel{}
+
+	if err := Test.FindOne(bson.M{"testEmbed.value": "embedded"}).Exec(found); err != nil {
+		t.Fatal("DB: FindOne by embedded field failed", err)
+	}
+
+	if _, ok := found.Relation11.(bson.ObjectId); !ok {
+		t.Error("DB: nothing populated - cast to bson object id failed for relation 11")
+	}
+
+	if _, ok := found.Relation1N.([]bson.ObjectId); !ok {
+		t.Error("DB: nothing populated - cast to bson object id slice failed for relation 1N")
+	}
+
+	testModels := []*TestModel{}
+
+	if err := Test.Find(bson.M{"deleted": false}).Populate("Relation11", "Relation1N").Exec(&testModels); err != nil || len(testModels) != 1 {
+		t.Fatal("DB: Find with population failed", err)
+	}
+
+	if relation, ok := testModels[0].Relation11.(*TestRelationModel); !ok || relation.RelationName != "some relation" {
+		t.Error("DB: 11 relation was not populated correctly")
+	}
+
+	if relations, ok := testModels[0].Relation1N.([]*TestRelationModel); !ok || len(relations) != 1 {
+		t.Error("DB: 1N relation was not populated correctly", testModels[0].Relation1N)
+	}
+
+	if err := Test.FindId(bson.NewObjectId()).Exec(found); err == nil {
+		t.Error("DB: expected not found error for unknown id")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("DB: expected not found error for unknown id", err)
+	}
+}
+
+func TestMemoryQueryOptions(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	for index, name := range []string{"Delta", "Alpha", "Charlie", "Bravo", "Echo"} {
+		saveTestModel(t, db, name, index)
+	}
+
+	testModels := []*TestModel{}
+
+	if err := Test.Find(bson.M{"number": bson.M{"$gte": 1}}).Sort("-name").Skip(1).Limit(2).Exec(&testModels); err != nil {
+		t.Fatal("DB: Find failed", err)
+	}
+
+	if len(testModels) != 2 || testModels[0].Name != "Charlie" || testModels[1].Name != "Bravo" {
+		t.Error("DB: sort, skip or limit were not applied correctly", testModels)
+	}
+
+	count, err := Test.Find(bson.M{"$or": []bson.M{{"name": bson.M{"$in": []string{"Alpha", "Echo"}}}, {"number": 0}}}).Count()
+
+	if err != nil || count != 3 {
+		t.Error("DB: Count with $or and $in failed", count, err)
+	}
+
+	count, err = Test.Find(bson.M{"name": bson.RegEx{Pattern: "^[a-c]", Options: "i"}}).Count()
+
+	if err != nil || count != 3 {
+		t.Error("DB: Count with regular expression failed", count, err)
+	}
+
+	selected := &TestModel{}
+
+	if err := Test.FindOne(bson.M{"name": "Delta"}).Select(bson.M{"name": 1}).Exec(selected); err != nil {
+		t.Fatal("DB: FindOne with selector failed", err)
+	}
+
+	if selected.Name != "Delta" || len(selected.RequiredField) > 0 || !selected.Id.Valid() {
+		t.Error("DB: selector was not applied correctly", selected)
+	}
+
+	//[]byte and bson.Binary values are compared as binary data
+	for name, data := range map[string]interface{}{"Binary A": []byte("a"), "Binary B": bson.Binary{Kind: 0x80, Data: []byte("b")}, "Binary C": []byte("c")} {
+		Test.collection.Insert(context.Background(), bson.M{"_id": bson.NewObjectId(), "name": name, "data": data})
+	}
+
+	if err := Test.Find(bson.M{"data": bson.M{"$gte": bson.Binary{Kind: 0x80, Data: []byte("b")}}}).Sort("-data").Exec(&testModels); err != nil {
+		t.Fatal("DB: Find with binary data failed", err)
+	}
+
+	if len(testModels) != 2 || testModels[0].Name != "Binary C" || testModels[1].Name != "Binary B" {
+		t.Error("DB: binary data was not compared correctly", testModels)
+	}
+}
+
+func TestMemoryUpsertAndDuplicates(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	if err := Test.CreateIndex(Index{Key: []string{"name"}, Unique: true}); err != nil {
+		t.Fatal("DB: index creation failed", err)
+	}
+
+	testModel := saveTestModel(t, db, "Unique", 1)
+
+	testModel.Number = 2
+
+	if err := testModel.Save(); err != nil {
+		t.Error("DB: update of existing document failed", err)
+	}
+
+	if count, _ := Test.Find(bson.M{"number": 2}).Count(); count != 1 {
+		t.Error("DB: expected the document to be replaced", count)
+	}
+
+	duplicate := &TestModel{}
+
+	Test.New(duplicate)
+
+	duplicate.Name = "Unique"
+	duplicate.RequiredField = "Test"
+	duplicate.Relation1N = []bson.ObjectId{}
+
+	if err := duplicate.Save(); err == nil {
+		t.Error("DB: expected duplicate error for unique index")
+	} else if _, ok := err.(*DuplicateError); !ok {
+		t.Error("DB: expected duplicate error for unique index", err)
+	}
+}
@@ -0,0 +1,288 @@
+package mongodm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+This file contains the update operators of the memory driver. Like filters, update documents are normalized with
+memoryFilter first, so they only consist of bson.M, []interface{} and the bson scalar types.
+*/
+
+//applyUpdate applies the update operators to the document, which is changed in place
+func applyUpdate(document bson.M, update bson.M) error {
+
+	if !isOperatorDocument(update) {
+		return fmt.Errorf("DB: The memory driver expects an update document with operators")
+	}
+
+	//apply the operators in a fixed order, the paths of different operators must not overlap anyway
+	operators := make([]string, 0, len(update))
+
+	for operator := range update {
+		operators = append(operators, operator)
+	}
+
+	sort.Strings(operators)
+
+	for _, operator := range operators {
+
+		fields, ok := update[operator].(bson.M)
+
+		if !ok {
+			return fmt.Errorf("DB: Operator '%v' expects a document", operator)
+		}
+
+		for path, argument := range fields {
+
+			if path == "_id" {
+				return fmt.Errorf("DB: Operator '%v' can not change the _id", operator)
+			}
+
+			var err error
+
+			switch operator {
+
+			case "$set":
+				err = updatePath(document, path, argument)
+
+			case "$unset":
+				deletePath(document, path)
+
+			case "$inc":
+				err = incrementPath(document, path, argument)
+
+			case "$min", "$max":
+
+				current, found := lookupPath(document, path)
+				result := compareValues(argument, current)
+
+				if !found || (operator == "$min" && result < 0) || (operator == "$max" && result > 0) {
+					err = updatePath(document, path, argument)
+				}
+
+			case "$push", "$addToSet":
+				err = appendPath(document, path, argument, operator == "$addToSet")
+
+			case "$pull":
+				err = pullPath(document, path, argument)
+
+			default:
+				return fmt.Errorf("DB: Update operator '%v' is not supported by the memory driver", operator)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//updatePath sets the value of a dotted path like setPath, but also supports array indexes and reports type conflicts
+func updatePath(document bson.M, path string, value interface{}) error {
+
+	parts := strings.Split(path, ".")
+	var current interface{} = document
+
+	for index, part := range parts {
+
+		last := index == len(parts)-1
+
+		switch typed := current.(type) {
+
+		case bson.M:
+
+			if last {
+				typed[part] = value
+				return nil
+			}
+
+			child, ok := typed[part]
+
+			if !ok || child == nil {
+				child = bson.M{}
+				typed[part] = child
+			}
+
+			current = child
+
+		case []interface{}:
+
+			position, err := strconv.Atoi(part)
+
+			if err != nil || position < 0 || position >= len(typed) {
+				return fmt.Errorf("DB: Can not set path '%v', '%v' is no index of the array", path, part)
+			}
+
+			if last {
+				typed[position] = value
+				return nil
+			}
+
+			current = typed[position]
+
+		default:
+			return fmt.Errorf("DB: Can not set path '%v', '%v' is no embedded document", path, strings.Join(parts[:index], "."))
+		}
+	}
+
+	return nil
+}
+
+//incrementPath adds the number to the value of the path, a missing value is set to the number
+func incrementPath(document bson.M, path string, number interface{}) error {
+
+	current, found := lookupPath(document, path)
+
+	if !found {
+		current = 0
+	}
+
+	sum, ok := addNumbers(current, number)
+
+	if !ok {
+		return fmt.Errorf("DB: Operator '$inc' expects numbers at '%v'", path)
+	}
+
+	return updatePath(document, path, sum)
+}
+
+//addNumbers adds two normalized numbers, the result is a float64 or int64 if one of them is
+func addNumbers(a interface{}, b interface{}) (interface{}, bool) {
+
+	numberA, okA := toFloat(a)
+	numberB, okB := toFloat(b)
+
+	if !okA || !okB {
+		return nil, false
+	}
+
+	_, floatA := a.(float64)
+	_, floatB := b.(float64)
+
+	if floatA || floatB {
+		return numberA + numberB, true
+	}
+
+	sum := integer(a) + integer(b)
+
+	_, longA := a.(int64)
+	_, longB := b.(int64)
+
+	if longA || longB {
+		return sum, true
+	}
+
+	return int(sum), true
+}
+
+func integer(value interface{}) int64 {
+
+	switch typed := value.(type) {
+	case int:
+		return int64(typed)
+	case int32:
+		return int64(typed)
+	case int64:
+		return typed
+	}
+
+	return 0
+}
+
+//arrayPath returns the array of the path, a missing value is an empty array
+func arrayPath(document bson.M, path string, operator string) ([]interface{}, error) {
+
+	current, found := lookupPath(document, path)
+
+	if !found || current == nil {
+		return []interface{}{}, nil
+	}
+
+	array, ok := current.([]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("DB: Operator '%v' expects an array at '%v'", operator, path)
+	}
+
+	return array, nil
+}
+
+//appendPath appends the value (or all values of $each) to the array of the path, unique only skips values which are contained already
+func appendPath(document bson.M, path string, argument interface{}, unique bool) error {
+
+	operator := "$push"
+
+	if unique {
+		operator = "$addToSet"
+	}
+
+	array, err := arrayPath(document, path, operator)
+
+	if err != nil {
+		return err
+	}
+
+	values := []interface{}{argument}
+
+	if modifiers, ok := argument.(bson.M); ok && isOperatorDocument(modifiers) {
+
+		if values, ok = modifiers["$each"].([]interface{}); !ok || len(modifiers) > 1 {
+			return fmt.Errorf("DB: Operator '%v' only supports the modifier '$each' in the memory driver", operator)
+		}
+	}
+
+	for _, value := range values {
+
+		if unique && matchEqual(fieldValues{values: array, found: true}, value) {
+			continue
+		}
+
+		array = append(array, value)
+	}
+
+	return updatePath(document, path, array)
+}
+
+//pullPath removes all elements of the array of the path which equal the value or match the condition
+func pullPath(document bson.M, path string, condition interface{}) error {
+
+	array, err := arrayPath(document, path, "$pull")
+
+	if err != nil {
+		return err
+	}
+
+	kept := []interface{}{}
+
+	for _, element := range array {
+
+		var match bool
+
+		embedded, isDocument := element.(bson.M)
+		query, isQuery := condition.(bson.M)
+
+		if isDocument && isQuery && !isOperatorDocument(query) {
+			match, err = matchDocument(embedded, query)
+		} else {
+			match, err = matchField(fieldValues{values: []interface{}{element}, found: true}, condition)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !match {
+			kept = append(kept, element)
+		}
+	}
+
+	return updatePath(document, path, kept)
+}
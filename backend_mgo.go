@@ -0,0 +1,532 @@
+package mongodm
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//mgoBackend implements the Backend interface with gopkg.in/mgo.v2 (default driver)
+type mgoBackend struct {
+	session *mgo.Session
+}
+
+var mgoModes = map[Mode]mgo.Mode{
+	Strong:             mgo.Strong,
+	Monotonic:          mgo.Monotonic,
+	Eventual:           mgo.Eventual,
+	Primary:            mgo.Primary,
+	PrimaryPreferred:   mgo.PrimaryPreferred,
+	Secondary:          mgo.Secondary,
+	SecondaryPreferred: mgo.SecondaryPreferred,
+	Nearest:            mgo.Nearest,
+}
+
+type mgoCollection struct {
+	collection *mgo.Collection
+	backend    *mgoBackend
+	options    *SessionOptions
+}
+
+//mgoSafe converts the write concern to the mgo type
+func mgoSafe(safe *Safe) *mgo.Safe {
+
+	return &mgo.Safe{
+		W:        safe.W,
+		WMode:    safe.WMode,
+		WTimeout: int(safe.WTimeout / time.Millisecond),
+		J:        safe.J,
+		FSync:    safe.FSync,
+	}
+}
+
+func dialMgo(config *Config) (*mgoBackend, error) {
+
+	var info *mgo.DialInfo
+	var uri *ConnectionURI
+
+	if len(config.URI) > 0 {
+
+		var err error
+
+		uri, err = ParseURI(config.URI)
+
+		if err != nil {
+			return nil, err
+		}
+
+		info, err = uri.DialInfo()
+
+		if err != nil {
+			return nil, err
+		}
+
+	} else if config.DialInfo == nil {
+		info = &mgo.DialInfo{
+			Addrs:    config.DatabaseHosts,
+			Database: config.DatabaseName,
+			Username: config.DatabaseUser,
+			Password: config.DatabasePassword,
+			Source:   config.DatabaseSource,
+		}
+	} else {
+		copied := *config.DialInfo // the dial info of the config must not be changed
+		info = &copied
+	}
+
+	settings := resolveSessionSettings(config, uri)
+
+	info.Timeout = settings.dialTimeout
+	info.PoolLimit = settings.poolLimit
+
+	session, err := mgo.DialWithInfo(info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session.SetMode(mgoModes[settings.mode], true)
+
+	if settings.socketTimeout > 0 {
+		session.SetSocketTimeout(settings.socketTimeout)
+	}
+
+	if settings.syncTimeout > 0 {
+		session.SetSyncTimeout(settings.syncTimeout)
+	}
+
+	if settings.safe != nil {
+		session.SetSafe(mgoSafe(settings.safe))
+	}
+
+	return &mgoBackend{session}, nil
+}
+
+func (self *mgoBackend) Collection(database string, name string, options *SessionOptions) BackendCollection {
+
+	return &mgoCollection{self.session.DB(database).C(name), self, options}
+}
+
+func (self *mgoBackend) Close() {
+
+	self.session.Close()
+}
+
+/*
+run executes fn on a clone of the root session. The session options are applied to the clone and
+if ctx carries a deadline it is applied as socket timeout of the clone. The method returns as soon as ctx is done,
+even if fn is still running - the clone gets closed afterwards. So fn must not touch any memory which is owned by
+the caller, decode results after run returned instead.
+
+"This behavior ensures that writes performed in the old session are necessarily observed
+when using the new session, as long as it was a strong or monotonic session.
+That said, it also means that long operations may cause other goroutines using the
+original session to wait." see: http://godoc.org/labix.org/v2/mgo#Session.Clone
+*/
+func (self *mgoBackend) run(ctx context.Context, options *SessionOptions, fn func(session *mgo.Session) error) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	session := self.session.Clone()
+
+	if options != nil {
+
+		if options.Mode != DefaultMode {
+			session.SetMode(mgoModes[options.Mode], true)
+		}
+
+		if options.Safe != nil {
+			session.SetSafe(mgoSafe(options.Safe))
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+
+		timeout := time.Until(deadline)
+
+		if timeout <= 0 {
+			session.Close()
+			return context.DeadlineExceeded
+		}
+
+		session.SetSocketTimeout(timeout)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		defer session.Close()
+
+		done <- mgoError(fn(session))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (self *mgoBackend) Ping(ctx context.Context) error {
+
+	return self.run(ctx, nil, func(session *mgo.Session) error {
+		return session.Ping()
+	})
+}
+
+//Refresh discards the sockets of the root session (broken sockets are kept by mgo until then) and checks the connection
+func (self *mgoBackend) Refresh(ctx context.Context) error {
+
+	self.session.Refresh()
+
+	return self.Ping(ctx)
+}
+
+func (self *mgoBackend) IsNetworkError(err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	if err == io.EOF || err.Error() == "no reachable servers" {
+		return true
+	}
+
+	_, ok := err.(net.Error)
+
+	return ok
+}
+
+//run executes fn with the collection on a clone of the root session (see: func (*mgoBackend) run)
+func (self *mgoCollection) run(ctx context.Context, fn func(collection *mgo.Collection) error) error {
+
+	return self.backend.run(ctx, self.options, func(session *mgo.Session) error {
+		return fn(self.collection.With(session))
+	})
+}
+
+func (self *mgoCollection) Name() string {
+
+	return self.collection.Name
+}
+
+func (self *mgoCollection) Find(ctx context.Context, filter interface{}, options *FindOptions) ([]bson.Raw, error) {
+
+	var raws []bson.Raw
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		var result []bson.Raw
+
+		query := collection.Find(filter)
+
+		if options != nil {
+
+			if options.Selector != nil {
+				query.Select(options.Selector)
+			}
+
+			if len(options.Sort) > 0 {
+				query.Sort(options.Sort...)
+			}
+
+			if options.Limit != 0 {
+				query.Limit(options.Limit)
+			}
+
+			if options.Skip != 0 {
+				query.Skip(options.Skip)
+			}
+		}
+
+		err := query.All(&result)
+
+		raws = result
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return raws, nil
+}
+
+func (self *mgoCollection) Count(ctx context.Context, filter interface{}) (int, error) {
+
+	var n int
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		count, err := collection.Find(filter).Count()
+
+		n = count
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (self *mgoCollection) Insert(ctx context.Context, document interface{}) error {
+
+	return self.run(ctx, func(collection *mgo.Collection) error {
+		return collection.Insert(document)
+	})
+}
+
+func (self *mgoCollection) UpsertId(ctx context.Context, id interface{}, document interface{}) error {
+
+	return self.run(ctx, func(collection *mgo.Collection) error {
+		_, err := collection.UpsertId(id, document)
+		return err
+	})
+}
+
+func (self *mgoCollection) Replace(ctx context.Context, filter interface{}, document interface{}) (bool, error) {
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+		return collection.Update(filter, document)
+	})
+
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (self *mgoCollection) Update(ctx context.Context, filter interface{}, update interface{}) (bool, error) {
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+		return collection.Update(filter, update)
+	})
+
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (self *mgoCollection) UpdateAll(ctx context.Context, filter interface{}, update interface{}) (int, error) {
+
+	var n int
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		info, err := collection.UpdateAll(filter, update)
+
+		if info != nil {
+			n = info.Matched
+		}
+
+		return err
+	})
+
+	return n, err
+}
+
+func (self *mgoCollection) FindAndUpdate(ctx context.Context, filter interface{}, update interface{}) (bson.Raw, bool, error) {
+
+	var raw bson.Raw
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		var result bson.Raw
+
+		_, err := collection.Find(filter).Apply(mgo.Change{Update: update, ReturnNew: true}, &result)
+
+		raw = result
+
+		return err
+	})
+
+	if err == mgo.ErrNotFound {
+		return bson.Raw{}, false, nil
+	} else if err != nil {
+		return bson.Raw{}, false, err
+	}
+
+	return raw, true, nil
+}
+
+func (self *mgoCollection) Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error) {
+
+	result := &BulkResult{Errors: map[int]error{}}
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		bulk := collection.Bulk()
+
+		if !ordered {
+			bulk.Unordered()
+		}
+
+		for _, operation := range operations {
+
+			if operation.Insert != nil {
+				bulk.Insert(operation.Insert)
+			} else {
+				bulk.Update(operation.Filter, operation.Update)
+			}
+		}
+
+		info, err := bulk.Run()
+
+		if info != nil {
+			result.Matched = info.Matched
+		}
+
+		bulkError, ok := err.(*mgo.BulkError)
+
+		if !ok {
+			return err
+		}
+
+		//errors of single operations are no failure of the bulk operation, unless the index is unknown (old servers)
+		for _, errorCase := range bulkError.Cases() {
+
+			if errorCase.Index < 0 {
+				return err
+			}
+
+			result.Errors[errorCase.Index] = mgoError(errorCase.Err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (self *mgoCollection) Remove(ctx context.Context, filter interface{}) (int, error) {
+
+	var n int
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		info, err := collection.RemoveAll(filter)
+
+		if info != nil {
+			n = info.Removed
+		}
+
+		return err
+	})
+
+	return n, err
+}
+
+func (self *mgoCollection) EnsureIndex(ctx context.Context, index Index) error {
+
+	return self.run(ctx, func(collection *mgo.Collection) error {
+		return collection.EnsureIndex(mgo.Index{
+			Key:         index.Key,
+			Name:        index.Name,
+			Unique:      index.Unique,
+			Sparse:      index.Sparse,
+			ExpireAfter: index.ExpireAfter,
+		})
+	})
+}
+
+func (self *mgoCollection) Indexes(ctx context.Context) ([]Index, error) {
+
+	var indexes []Index
+
+	err := self.run(ctx, func(collection *mgo.Collection) error {
+
+		mgoIndexes, err := collection.Indexes()
+
+		// a collection which does not exist yet has no indexes
+		if queryError, ok := err.(*mgo.QueryError); ok && (queryError.Code == 26 || strings.Contains(queryError.Message, "ns does not exist")) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		indexes = make([]Index, 0, len(mgoIndexes))
+
+		for _, index := range mgoIndexes {
+			indexes = append(indexes, Index{
+				Name:        index.Name,
+				Key:         index.Key,
+				Unique:      index.Unique,
+				Sparse:      index.Sparse,
+				ExpireAfter: index.ExpireAfter,
+			})
+		}
+
+		return nil
+	})
+
+	return indexes, err
+}
+
+func (self *mgoCollection) DropIndex(ctx context.Context, name string) error {
+
+	return self.run(ctx, func(collection *mgo.Collection) error {
+		return collection.DropIndexName(name)
+	})
+}
+
+func (self *mgoCollection) SetValidator(ctx context.Context, validator bson.M, level ValidationLevel, action ValidationAction) error {
+
+	return self.run(ctx, func(collection *mgo.Collection) error {
+
+		result := bson.M{}
+		err := collection.Database.Run(validatorCommand("collMod", collection.Name, validator, level, action), &result)
+
+		// collMod fails with NamespaceNotFound if the collection does not exist yet
+		if mgoErrorCode(err) == 26 {
+			err = collection.Database.Run(validatorCommand("create", collection.Name, validator, level, action), &result)
+		}
+
+		return err
+	})
+}
+
+//mgoError maps mgo errors to the error types of the ODM
+func mgoError(err error) error {
+
+	if mgo.IsDup(err) {
+		return &DuplicateError{&QueryError{"Duplicate key"}}
+	} else if mgoErrorCode(err) == 121 {
+		return &ValidationError{&QueryError{"DB: Document failed validation"}, nil}
+	}
+
+	return err
+}
+
+//mgoErrorCode returns the server error code of an mgo error or 0
+func mgoErrorCode(err error) int {
+
+	switch typedError := err.(type) {
+	case *mgo.QueryError:
+		return typedError.Code
+	case *mgo.LastError:
+		return typedError.Code
+	}
+
+	return 0
+}
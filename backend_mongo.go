@@ -0,0 +1,673 @@
+package mongodm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	mongobson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+mongoBackend implements the Backend interface with the official driver (go.mongodb.org/mongo-driver).
+Documents are still serialized with gopkg.in/mgo.v2/bson and passed as raw bson to the driver, so bson.ObjectId values,
+inline structs and untyped relation fields behave exactly like with the mgo driver.
+*/
+type mongoBackend struct {
+	client   *mongo.Client
+	database string
+}
+
+type mongoCollection struct {
+	collection *mongo.Collection
+}
+
+func dialMongo(config *Config) (*mongoBackend, error) {
+
+	clientOptions := options.Client()
+	credential := options.Credential{}
+
+	var database string
+	var uri *ConnectionURI
+
+	if len(config.URI) > 0 {
+
+		var err error
+
+		uri, err = ParseURI(config.URI)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the driver understands all options of the connection string (including the srv lookup) by itself
+		clientOptions.ApplyURI(config.URI)
+
+		database = uri.Database
+
+	} else {
+
+		if config.DialInfo == nil {
+
+			clientOptions.SetHosts(config.DatabaseHosts)
+
+			database = config.DatabaseName
+			credential.Username = config.DatabaseUser
+			credential.Password = config.DatabasePassword
+			credential.AuthSource = config.DatabaseSource
+
+		} else {
+
+			info := config.DialInfo
+
+			clientOptions.SetHosts(info.Addrs)
+			clientOptions.SetDirect(info.Direct)
+
+			if len(info.ReplicaSetName) > 0 {
+				clientOptions.SetReplicaSet(info.ReplicaSetName)
+			}
+
+			database = info.Database
+			credential.Username = info.Username
+			credential.Password = info.Password
+			credential.AuthSource = info.Source
+			credential.AuthMechanism = info.Mechanism
+		}
+
+		if len(credential.Username) > 0 {
+
+			// like mgo: the credentials are checked against the database if no source was set
+			if len(credential.AuthSource) == 0 && len(database) > 0 {
+				credential.AuthSource = database
+			}
+
+			clientOptions.SetAuth(credential)
+		}
+	}
+
+	if len(database) == 0 {
+		database = "test" // mgo default database
+	}
+
+	settings := resolveSessionSettings(config, uri)
+
+	clientOptions.SetConnectTimeout(settings.dialTimeout)
+	clientOptions.SetReadPreference(mongoReadPreference(settings.mode))
+
+	// like mgo: the sync timeout is the time to wait for a suitable server, default is the dial timeout
+	if settings.syncTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(settings.syncTimeout)
+	} else {
+		clientOptions.SetServerSelectionTimeout(settings.dialTimeout)
+	}
+
+	if settings.socketTimeout > 0 {
+		clientOptions.SetSocketTimeout(settings.socketTimeout)
+	}
+
+	if settings.poolLimit > 0 {
+		clientOptions.SetMaxPoolSize(uint64(settings.poolLimit))
+	}
+
+	if settings.safe != nil {
+		clientOptions.SetWriteConcern(mongoWriteConcern(settings.safe))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.dialTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, clientOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// mongo.Connect does not wait for a server, but Connect() must fail like mgo.DialWithInfo does
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	return &mongoBackend{client, database}, nil
+}
+
+func (self *mongoBackend) Collection(database string, name string, sessionOptions *SessionOptions) BackendCollection {
+
+	if len(database) == 0 {
+		database = self.database
+	}
+
+	collectionOptions := options.Collection()
+
+	if sessionOptions != nil {
+
+		if sessionOptions.Mode != DefaultMode {
+			collectionOptions.SetReadPreference(mongoReadPreference(sessionOptions.Mode))
+		}
+
+		if sessionOptions.Safe != nil {
+			collectionOptions.SetWriteConcern(mongoWriteConcern(sessionOptions.Safe))
+		}
+	}
+
+	return &mongoCollection{self.client.Database(database).Collection(name, collectionOptions)}
+}
+
+func (self *mongoBackend) Close() {
+
+	self.client.Disconnect(context.Background())
+}
+
+func (self *mongoBackend) Ping(ctx context.Context) error {
+
+	return self.client.Ping(ctx, nil)
+}
+
+//Refresh only checks the connection, the driver replaces broken connections of its pool by itself
+func (self *mongoBackend) Refresh(ctx context.Context) error {
+
+	return self.Ping(ctx)
+}
+
+func (self *mongoBackend) IsNetworkError(err error) bool {
+
+	return mongo.IsNetworkError(err)
+}
+
+//mongoDocument converts a value which is serializable with the mgo bson package to a raw document of the official driver
+func mongoDocument(value interface{}) (mongobson.Raw, error) {
+
+	raw, err := rawDocument(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mongobson.Raw(raw.Data), nil
+}
+
+/*
+mongoReadPreference maps the session mode to a read preference. The official driver has no session modes, so
+Strong reads from the primary, Monotonic prefers the primary and Eventual reads from the nearest member.
+*/
+func mongoReadPreference(mode Mode) *readpref.ReadPref {
+
+	switch mode {
+	case Strong, Primary:
+		return readpref.Primary()
+	case Eventual, Nearest:
+		return readpref.Nearest()
+	case Secondary:
+		return readpref.Secondary()
+	case SecondaryPreferred:
+		return readpref.SecondaryPreferred()
+	}
+
+	return readpref.PrimaryPreferred()
+}
+
+//mongoWriteConcern converts the write concern to the type of the official driver (FSync is mapped to journaling)
+func mongoWriteConcern(safe *Safe) *writeconcern.WriteConcern {
+
+	concern := &writeconcern.WriteConcern{WTimeout: safe.WTimeout}
+
+	if len(safe.WMode) > 0 {
+		concern.W = safe.WMode
+	} else if safe.W > 0 {
+		concern.W = safe.W
+	}
+
+	if safe.J || safe.FSync {
+		journal := true
+		concern.Journal = &journal
+	}
+
+	return concern
+}
+
+//mongoSort converts mgo sort fields (e.g. "-createdAt") to a sort document
+func mongoSort(fields []string) mongobson.D {
+
+	sort := mongobson.D{}
+
+	for _, field := range fields {
+
+		order := 1
+
+		if strings.HasPrefix(field, "-") {
+			order = -1
+			field = field[1:]
+		} else if strings.HasPrefix(field, "+") {
+			field = field[1:]
+		}
+
+		sort = append(sort, mongobson.E{Key: field, Value: order})
+	}
+
+	return sort
+}
+
+//mongoError maps driver errors to the error types of the ODM
+func mongoError(err error) error {
+
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return &DuplicateError{&QueryError{"Duplicate key"}}
+	}
+
+	if serverError, ok := err.(mongo.ServerError); ok && serverError.HasErrorCode(121) {
+		return &ValidationError{&QueryError{"DB: Document failed validation"}, nil}
+	}
+
+	return err
+}
+
+func (self *mongoCollection) Name() string {
+
+	return self.collection.Name()
+}
+
+func (self *mongoCollection) Find(ctx context.Context, filter interface{}, findOptions *FindOptions) ([]bson.Raw, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mongoOptions := options.Find()
+
+	if findOptions != nil {
+
+		if findOptions.Selector != nil {
+
+			projection, err := mongoDocument(findOptions.Selector)
+
+			if err != nil {
+				return nil, err
+			}
+
+			mongoOptions.SetProjection(projection)
+		}
+
+		if len(findOptions.Sort) > 0 {
+			mongoOptions.SetSort(mongoSort(findOptions.Sort))
+		}
+
+		if findOptions.Limit != 0 {
+			mongoOptions.SetLimit(int64(findOptions.Limit))
+		}
+
+		if findOptions.Skip != 0 {
+			mongoOptions.SetSkip(int64(findOptions.Skip))
+		}
+	}
+
+	cursor, err := self.collection.Find(ctx, mongoFilter, mongoOptions)
+
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	defer cursor.Close(ctx)
+
+	raws := []bson.Raw{}
+
+	for cursor.Next(ctx) {
+
+		// the cursor reuses its buffer, so the current document has to be copied
+		data := make([]byte, len(cursor.Current))
+		copy(data, cursor.Current)
+
+		raws = append(raws, bson.Raw{Kind: 0x03, Data: data})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, mongoError(err)
+	}
+
+	return raws, nil
+}
+
+func (self *mongoCollection) Count(ctx context.Context, filter interface{}) (int, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := self.collection.CountDocuments(ctx, mongoFilter)
+
+	return int(count), mongoError(err)
+}
+
+func (self *mongoCollection) Insert(ctx context.Context, document interface{}) error {
+
+	mongoDoc, err := mongoDocument(document)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = self.collection.InsertOne(ctx, mongoDoc)
+
+	return mongoError(err)
+}
+
+func (self *mongoCollection) UpsertId(ctx context.Context, id interface{}, document interface{}) error {
+
+	mongoFilter, err := mongoDocument(bson.M{"_id": id})
+
+	if err != nil {
+		return err
+	}
+
+	mongoDoc, err := mongoDocument(document)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = self.collection.ReplaceOne(ctx, mongoFilter, mongoDoc, options.Replace().SetUpsert(true))
+
+	return mongoError(err)
+}
+
+func (self *mongoCollection) Replace(ctx context.Context, filter interface{}, document interface{}) (bool, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return false, err
+	}
+
+	mongoDoc, err := mongoDocument(document)
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := self.collection.ReplaceOne(ctx, mongoFilter, mongoDoc)
+
+	if err != nil {
+		return false, mongoError(err)
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+func (self *mongoCollection) Update(ctx context.Context, filter interface{}, update interface{}) (bool, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return false, err
+	}
+
+	mongoUpdate, err := mongoDocument(update)
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := self.collection.UpdateOne(ctx, mongoFilter, mongoUpdate)
+
+	if err != nil {
+		return false, mongoError(err)
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+func (self *mongoCollection) UpdateAll(ctx context.Context, filter interface{}, update interface{}) (int, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	mongoUpdate, err := mongoDocument(update)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := self.collection.UpdateMany(ctx, mongoFilter, mongoUpdate)
+
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return int(result.MatchedCount), nil
+}
+
+func (self *mongoCollection) FindAndUpdate(ctx context.Context, filter interface{}, update interface{}) (bson.Raw, bool, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return bson.Raw{}, false, err
+	}
+
+	mongoUpdate, err := mongoDocument(update)
+
+	if err != nil {
+		return bson.Raw{}, false, err
+	}
+
+	result, err := self.collection.FindOneAndUpdate(ctx, mongoFilter, mongoUpdate, options.FindOneAndUpdate().SetReturnDocument(options.After)).Raw()
+
+	if err == mongo.ErrNoDocuments {
+		return bson.Raw{}, false, nil
+	} else if err != nil {
+		return bson.Raw{}, false, mongoError(err)
+	}
+
+	data := make([]byte, len(result))
+	copy(data, result)
+
+	return bson.Raw{Kind: 0x03, Data: data}, true, nil
+}
+
+func (self *mongoCollection) Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error) {
+
+	result := &BulkResult{Errors: map[int]error{}}
+
+	if len(operations) == 0 {
+		return result, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(operations))
+
+	for _, operation := range operations {
+
+		if operation.Insert != nil {
+
+			mongoDoc, err := mongoDocument(operation.Insert)
+
+			if err != nil {
+				return nil, err
+			}
+
+			models = append(models, mongo.NewInsertOneModel().SetDocument(mongoDoc))
+
+			continue
+		}
+
+		mongoFilter, err := mongoDocument(operation.Filter)
+
+		if err != nil {
+			return nil, err
+		}
+
+		mongoUpdate, err := mongoDocument(operation.Update)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Replace {
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(mongoFilter).SetReplacement(mongoUpdate))
+		} else {
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(mongoFilter).SetUpdate(mongoUpdate))
+		}
+	}
+
+	info, err := self.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+
+	if info != nil {
+		result.Matched = int(info.MatchedCount)
+	}
+
+	//errors of single operations are no failure of the bulk operation
+	if exception, ok := err.(mongo.BulkWriteException); ok && exception.WriteConcernError == nil {
+
+		for _, writeError := range exception.WriteErrors {
+			result.Errors[writeError.Index] = mongoError(writeError.WriteError)
+		}
+
+	} else if err != nil {
+		return nil, mongoError(err)
+	}
+
+	return result, nil
+}
+
+func (self *mongoCollection) Remove(ctx context.Context, filter interface{}) (int, error) {
+
+	mongoFilter, err := mongoDocument(filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := self.collection.DeleteMany(ctx, mongoFilter)
+
+	if err != nil {
+		return 0, mongoError(err)
+	}
+
+	return int(result.DeletedCount), nil
+}
+
+func (self *mongoCollection) EnsureIndex(ctx context.Context, index Index) error {
+
+	indexOptions := options.Index().SetUnique(index.Unique).SetSparse(index.Sparse)
+
+	if len(index.Name) > 0 {
+		indexOptions.SetName(index.Name)
+	}
+
+	if index.ExpireAfter > 0 {
+		indexOptions.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+
+	_, err := self.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: mongoSort(index.Key), Options: indexOptions})
+
+	return mongoError(err)
+}
+
+func (self *mongoCollection) Indexes(ctx context.Context) ([]Index, error) {
+
+	cursor, err := self.collection.Indexes().List(ctx)
+
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var specifications []struct {
+		Name               string      `bson:"name"`
+		Key                mongobson.D `bson:"key"`
+		Unique             bool        `bson:"unique"`
+		Sparse             bool        `bson:"sparse"`
+		ExpireAfterSeconds *int64      `bson:"expireAfterSeconds"`
+	}
+
+	if err := cursor.All(ctx, &specifications); err != nil {
+		return nil, mongoError(err)
+	}
+
+	indexes := make([]Index, 0, len(specifications))
+
+	for _, specification := range specifications {
+
+		index := Index{
+			Name:   specification.Name,
+			Key:    make([]string, 0, len(specification.Key)),
+			Unique: specification.Unique,
+			Sparse: specification.Sparse,
+		}
+
+		if specification.ExpireAfterSeconds != nil {
+			index.ExpireAfter = time.Duration(*specification.ExpireAfterSeconds) * time.Second
+		}
+
+		// same key format as mgo: "field", "-field" or "$type:field" (e.g. "$text:name")
+		for _, element := range specification.Key {
+
+			switch value := element.Value.(type) {
+			case string:
+				index.Key = append(index.Key, "$"+value+":"+element.Key)
+			case int32:
+				index.Key = append(index.Key, mongoIndexKey(element.Key, float64(value)))
+			case int64:
+				index.Key = append(index.Key, mongoIndexKey(element.Key, float64(value)))
+			case float64:
+				index.Key = append(index.Key, mongoIndexKey(element.Key, value))
+			default:
+				index.Key = append(index.Key, element.Key)
+			}
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+//mongoIndexKey returns the key of an index field, prefixed with "-" for descending order
+func mongoIndexKey(field string, order float64) string {
+
+	if order < 0 {
+		return "-" + field
+	}
+
+	return field
+}
+
+func (self *mongoCollection) DropIndex(ctx context.Context, name string) error {
+
+	_, err := self.collection.Indexes().DropOne(ctx, name)
+
+	return mongoError(err)
+}
+
+func (self *mongoCollection) SetValidator(ctx context.Context, validator bson.M, level ValidationLevel, action ValidationAction) error {
+
+	database := self.collection.Database()
+	command, err := mongoDocument(validatorCommand("collMod", self.collection.Name(), validator, level, action))
+
+	if err != nil {
+		return err
+	}
+
+	err = database.RunCommand(ctx, command).Err()
+
+	// collMod fails with NamespaceNotFound if the collection does not exist yet
+	if serverError, ok := err.(mongo.ServerError); ok && serverError.HasErrorCode(26) {
+
+		if command, err = mongoDocument(validatorCommand("create", self.collection.Name(), validator, level, action)); err != nil {
+			return err
+		}
+
+		err = database.RunCommand(ctx, command).Err()
+	}
+
+	return mongoError(err)
+}
@@ -0,0 +1,386 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+InsertMany inserts all documents of the slice (e.g. []*models.User) with as few database requests as possible. Like
+Save, every document is validated and gets its id (unless it was set manually), timestamps and version. Documents which
+were not initialized with Model.New are initialized with this model.
+
+Ordered documents are inserted in the order of the slice and the first failed document stops the insert, so all
+following documents are not written. Otherwise all valid documents are inserted. If documents could not be written,
+a *BulkError reports the error of each of them by its index.
+
+For example:
+	users := []*models.User{{FirstName: "Max"}, {FirstName: "Moritz"}}
+
+	err := User.InsertMany(users, false)
+
+	if bulkError, ok := err.(*mongodm.BulkError); ok {
+		//bulkError.Errors[1] is a *mongodm.DuplicateError if Moritz exists already
+	}
+*/
+func (self *Model) InsertMany(documents interface{}, ordered bool) error {
+
+	return self.InsertManyContext(context.Background(), documents, ordered)
+}
+
+//InsertManyContext works like InsertMany but is canceled as soon as the given context is done.
+func (self *Model) InsertManyContext(ctx context.Context, documents interface{}, ordered bool) error {
+
+	return self.bulkWrite(ctx, "InsertMany", documents, ordered, true)
+}
+
+/*
+SaveAll saves all documents of the slice like Save, but groups the writes into one bulk operation. New documents are
+inserted, existing ones are updated with their changed fields (see: func (*DocumentBase) ChangedFields). Ordered
+documents are written in the order of the slice and the first failed document stops the write, see: func (*Model) InsertMany.
+
+With versioning (see: ModelConfig.Versioning) existing documents are written one by one, because each of them needs
+its own conflict check.
+
+For example:
+	for _, user := range users {
+		user.Newsletter = true
+	}
+
+	err := User.SaveAll(users, false)
+*/
+func (self *Model) SaveAll(documents interface{}, ordered bool) error {
+
+	return self.SaveAllContext(context.Background(), documents, ordered)
+}
+
+//SaveAllContext works like SaveAll but is canceled as soon as the given context is done.
+func (self *Model) SaveAllContext(ctx context.Context, documents interface{}, ordered bool) error {
+
+	return self.bulkWrite(ctx, "SaveAll", documents, ordered, false)
+}
+
+//bulkWrite validates and prepares all documents, writes them with bulk operations and collects the errors by index
+func (self *Model) bulkWrite(ctx context.Context, name string, documents interface{}, ordered bool, insert bool) error {
+
+	bases, err := self.bulkDocuments(name, documents)
+
+	if err != nil || len(bases) == 0 {
+		return err
+	}
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("%v on collection '%v'", name, self.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	writer := &bulkWriter{model: self, ordered: ordered, errs: map[int]error{}, pendings: map[int]*pendingWrite{}, sent: map[int]bool{}}
+
+	//every document is validated before any of them is changed
+	for index, document := range bases {
+
+		if err := document.checkSave(); err != nil {
+			writer.fail(index, err)
+		}
+	}
+
+	for index, document := range bases {
+
+		if writer.stopped(index) {
+			break
+		} else if writer.errs[index] != nil {
+			continue
+		}
+
+		pending, err := document.prepare(ctx, insert)
+
+		if err != nil {
+			writer.fail(index, err)
+			continue
+		}
+
+		writer.pendings[index] = pending
+
+		if pending.insert || !self.config.Versioning {
+			writer.add(index, pending)
+			continue
+		}
+
+		//the operations before an ordered versioned document are written first
+		if ordered {
+
+			if err := writer.flush(ctx); err != nil {
+				return writer.abort(err)
+			}
+		}
+
+		if writer.stopped(index) {
+			break
+		}
+
+		writer.sent[index] = true
+
+		if err := pending.document.writeVersion(ctx, pending.raw, pending.outdated); err != nil {
+			writer.fail(index, err)
+		}
+	}
+
+	if err := writer.flush(ctx); err != nil {
+		return writer.abort(err)
+	}
+
+	//an ordered write stops at the first failed document
+	for index := writer.first + 1; writer.ordered && writer.failed && index < len(bases); index++ {
+
+		if writer.errs[index] == nil && !writer.sent[index] {
+			writer.errs[index] = &QueryError{fmt.Sprintf("DB: Document was not written because the ordered %v stopped at document %v", name, writer.first)}
+		}
+	}
+
+	for index, pending := range writer.pendings {
+
+		if writer.errs[index] != nil {
+			pending.rollback()
+		} else if err := pending.written(); err != nil {
+			writer.errs[index] = err
+		}
+	}
+
+	if len(writer.errs) > 0 {
+		return &BulkError{&QueryError{fmt.Sprintf("DB: %v of %v documents could not be written", len(writer.errs), len(bases))}, writer.errs}
+	}
+
+	return nil
+}
+
+//bulkWriter collects the prepared documents of InsertMany and SaveAll and writes them with bulk operations
+type bulkWriter struct {
+	model    *Model
+	ordered  bool
+	errs     map[int]error         // errors by index of the document
+	pendings map[int]*pendingWrite // prepared documents by index, also the ones which are not written
+
+	operations []BulkOperation // operations of the next bulk operation
+	positions  []int           // index of the document of each operation
+	sent       map[int]bool    // documents which were handed over to the database
+
+	first  int // lowest index of a failed document
+	failed bool
+}
+
+//fail records the error of a document
+func (self *bulkWriter) fail(index int, err error) {
+
+	self.errs[index] = err
+
+	if !self.failed || index < self.first {
+		self.first, self.failed = index, true
+	}
+}
+
+//stopped reports whether an ordered write must not write the document anymore
+func (self *bulkWriter) stopped(index int) bool {
+
+	return self.ordered && self.failed && self.first < index
+}
+
+//abort resets the documents which failed before the whole bulk operation failed and returns its error
+func (self *bulkWriter) abort(err error) error {
+
+	for index := range self.errs {
+
+		if pending, ok := self.pendings[index]; ok {
+			pending.rollback()
+		}
+	}
+
+	return err
+}
+
+//add appends the operation of a prepared document to the next bulk operation
+func (self *bulkWriter) add(index int, pending *pendingWrite) {
+
+	operation, err := pending.operation()
+
+	if err != nil {
+		self.fail(index, err)
+		return
+	}
+
+	self.operations = append(self.operations, operation)
+	self.positions = append(self.positions, index)
+}
+
+//flush sends the collected operations and resolves unmatched updates, only an error of the whole bulk operation is returned
+func (self *bulkWriter) flush(ctx context.Context) error {
+
+	if len(self.operations) == 0 {
+		return nil
+	}
+
+	result, err := self.model.collection.Bulk(ctx, self.operations, self.ordered)
+
+	if err != nil {
+
+		for _, index := range self.positions {
+			self.pendings[index].rollback()
+		}
+
+		return err
+	}
+
+	for position, err := range result.Errors {
+		self.fail(self.positions[position], err)
+	}
+
+	updates := []int{}
+
+	//an ordered bulk operation does not execute the operations after a failed one
+	for _, index := range self.positions {
+
+		if self.stopped(index) {
+			continue
+		}
+
+		self.sent[index] = true
+
+		if !self.pendings[index].insert && self.errs[index] == nil {
+			updates = append(updates, index)
+		}
+	}
+
+	self.operations, self.positions = nil, nil
+
+	if result.Matched >= len(updates) {
+		return nil
+	}
+
+	return self.resolveUnmatched(ctx, updates)
+}
+
+/*
+resolveUnmatched handles the updates whose filter matched no document, like Save does. The bulk result only reports
+the number of matched documents, so the updated documents are looked up if it is lower than expected.
+*/
+func (self *bulkWriter) resolveUnmatched(ctx context.Context, updates []int) error {
+
+	ids := make([]bson.ObjectId, len(updates))
+
+	for position, index := range updates {
+		ids[position] = self.pendings[index].document.Id
+	}
+
+	raws, err := self.model.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, &FindOptions{Selector: bson.M{"_id": 1}})
+
+	if err != nil {
+		return err
+	}
+
+	found := map[bson.ObjectId]bool{}
+
+	for _, raw := range raws {
+
+		stored := struct {
+			Id bson.ObjectId `bson:"_id"`
+		}{}
+
+		if err := raw.Unmarshal(&stored); err != nil {
+			return err
+		}
+
+		found[stored.Id] = true
+	}
+
+	for _, index := range updates {
+
+		pending := self.pendings[index]
+
+		if found[pending.document.Id] {
+			continue
+		}
+
+		if err := pending.document.unmatched(ctx, pending.raw); err != nil {
+			self.fail(index, err)
+		}
+	}
+
+	return nil
+}
+
+//bulkDocuments returns the documents of a slice, uninitialized documents are initialized with the model
+func (self *Model) bulkDocuments(name string, documents interface{}) ([]*DocumentBase, error) {
+
+	value := reflect.ValueOf(documents)
+
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Slice {
+		return nil, &SchemaError{&QueryError{fmt.Sprintf("DB: %v needs a slice of documents (e.g. []*models.User), %T given", name, documents)}}
+	}
+
+	bases := make([]*DocumentBase, value.Len())
+
+	for index := range bases {
+
+		element := value.Index(index)
+		document, ok := element.Interface().(IDocumentBase)
+		tracked, isTracked := element.Interface().(documentBase)
+
+		if !ok || !isTracked || reflect.ValueOf(document).IsNil() {
+			return nil, &SchemaError{&QueryError{fmt.Sprintf("DB: %v needs a slice of documents (e.g. []*models.User), element %v is %v", name, index, element.Type())}}
+		}
+
+		base := tracked.base()
+
+		if base.model == nil || base.document == nil {
+			self.New(document)
+		} else if base.model != self {
+			return nil, &SchemaError{&QueryError{fmt.Sprintf("DB: Document %v of %v belongs to the model '%v' and not to '%v'", index, name, base.model.name, self.name)}}
+		}
+
+		bases[index] = base
+	}
+
+	return bases, nil
+}
+
+//operation returns the bulk operation which writes the prepared document like Save
+func (self *pendingWrite) operation() (BulkOperation, error) {
+
+	document := self.document
+
+	if self.insert {
+		return BulkOperation{Insert: self.raw}, nil
+	}
+
+	filter := bson.M{"_id": document.Id}
+
+	if self.outdated || document.snapshot == nil {
+		return BulkOperation{Filter: filter, Update: self.raw, Replace: true}, nil
+	}
+
+	update, err := document.changes(self.raw)
+
+	if err != nil {
+		return BulkOperation{}, err
+	}
+
+	//an update needs operators, the timestamp is written anyway
+	if len(update) == 0 {
+		update = bson.M{"$set": bson.M{"updatedAt": document.UpdatedAt}}
+	}
+
+	return BulkOperation{Filter: filter, Update: update}, nil
+}
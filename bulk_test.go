@@ -0,0 +1,209 @@
+package mongodm
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func bulkTestModels(names ...string) []*TestModel {
+
+	documents := []*TestModel{}
+
+	for number, name := range names {
+		documents = append(documents, &TestModel{Name: name, Number: number, RequiredField: "Test", Relation1N: []bson.ObjectId{}})
+	}
+
+	return documents
+}
+
+func TestBulkInsertMany(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	if err := Test.CreateIndex(Index{Key: []string{"name"}, Unique: true}); err != nil {
+		t.Fatal("DB: index creation failed", err)
+	}
+
+	saveTestModel(t, db, "Existing", 0)
+
+	documents := bulkTestModels("Alpha", "Existing", "Beta", "Gamma")
+	documents[2].RequiredField = ""
+
+	err := Test.InsertMany(documents, false)
+
+	bulkError, ok := err.(*BulkError)
+
+	if !ok || len(bulkError.Errors) != 2 {
+		t.Fatal("DB: expected bulk error for two documents", err)
+	}
+
+	if _, ok := bulkError.Errors[1].(*DuplicateError); !ok {
+		t.Error("DB: expected duplicate error by index", bulkError.Errors)
+	}
+
+	if _, ok := bulkError.Errors[2].(*ValidationError); !ok {
+		t.Error("DB: expected validation error by index", bulkError.Errors)
+	}
+
+	//failed documents stay new
+	if !documents[0].Id.Valid() || !documents[3].Id.Valid() || len(documents[1].Id) > 0 || len(documents[2].Id) > 0 {
+		t.Error("DB: unexpected ids after insert", documents[0].Id, documents[1].Id, documents[2].Id, documents[3].Id)
+	}
+
+	if documents[0].CreatedAt.IsZero() || documents[0].IsDirty() {
+		t.Error("DB: inserted document was not prepared like Save")
+	}
+
+	if count, _ := Test.Find().Count(); count != 3 {
+		t.Error("DB: unexpected number of documents", count)
+	}
+
+	//an ordered insert stops at the first failed document
+	documents = bulkTestModels("Delta", "Alpha", "Epsilon")
+
+	bulkError, ok = Test.InsertMany(documents, true).(*BulkError)
+
+	if !ok || len(bulkError.Errors) != 2 || bulkError.Errors[2] == nil {
+		t.Fatal("DB: expected bulk error for the failed and the following document", bulkError)
+	}
+
+	if _, ok := bulkError.Errors[1].(*DuplicateError); !ok {
+		t.Error("DB: expected duplicate error by index", bulkError.Errors)
+	}
+
+	if count, _ := Test.Find(bson.M{"name": bson.M{"$in": []string{"Delta", "Epsilon"}}}).Count(); count != 1 || len(documents[2].Id) > 0 {
+		t.Error("DB: ordered insert did not stop", count)
+	}
+
+	if err := Test.InsertMany(&[]*TestModel{}, true); err != nil {
+		t.Error("DB: empty insert failed", err)
+	}
+
+	if err := Test.InsertMany([]string{"Alpha"}, true); err == nil {
+		t.Error("DB: expected schema error for a slice of non documents")
+	}
+}
+
+func TestBulkSaveAll(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestVersionModel{}, "versioned", &ModelConfig{Versioning: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestVersionModel")
+	documents := []*TestVersionModel{{Name: "Max"}, {Name: "Moritz"}}
+
+	if err := model.SaveAll(documents, true); err != nil {
+		t.Fatal("DB: documents could not be inserted", err)
+	}
+
+	//another request changes the second document
+	changed := &TestVersionModel{}
+
+	if err := model.FindId(documents[1].Id).Exec(changed); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	changed.Name = "Changed"
+
+	if err := changed.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	documents[0].Name = "Max Mustermann"
+	documents[1].Name = "Moritz Mustermann"
+	documents = append(documents, &TestVersionModel{Name: "Erika"})
+
+	bulkError, ok := model.SaveAll(documents, false).(*BulkError)
+
+	if !ok || len(bulkError.Errors) != 1 {
+		t.Fatal("DB: expected bulk error for one document", bulkError)
+	}
+
+	if _, ok := bulkError.Errors[1].(*ConflictError); !ok {
+		t.Error("DB: expected conflict error by index", bulkError.Errors)
+	}
+
+	if documents[0].Version != 2 || documents[1].Version != 1 || documents[2].Version != 1 {
+		t.Error("DB: unexpected versions", documents[0].Version, documents[1].Version, documents[2].Version)
+	}
+
+	if count, _ := model.Find(bson.M{"name": bson.M{"$in": []string{"Max Mustermann", "Changed", "Erika"}}}).Count(); count != 3 {
+		t.Error("DB: documents were not saved", count)
+	}
+
+	//an ordered save stops at the conflict
+	documents = append(documents, &TestVersionModel{Name: "Erika Mustermann"})
+
+	bulkError, ok = model.SaveAll(documents, true).(*BulkError)
+
+	if !ok || len(bulkError.Errors) != 3 || bulkError.Errors[0] != nil {
+		t.Fatal("DB: expected bulk error for the conflict and the following documents", bulkError)
+	}
+
+	if documents[0].Version != 3 || documents[2].Version != 1 || len(documents[3].Id) > 0 {
+		t.Error("DB: ordered save did not stop", documents[0].Version, documents[2].Version, documents[3].Id)
+	}
+
+	//like Save, a document which was removed in the meantime is stored again
+	unversioned := db.Model("testmodel")
+	testModels := bulkTestModels("Alpha", "Beta")
+
+	if err := unversioned.SaveAll(testModels, false); err != nil {
+		t.Fatal("DB: documents could not be inserted", err)
+	}
+
+	unversioned.Purge(bson.M{"name": "Beta"})
+
+	testModels[0].Number = 10
+	testModels[1].Number = 20
+
+	if err := unversioned.SaveAll(testModels, false); err != nil {
+		t.Fatal("DB: documents could not be saved", err)
+	}
+
+	if count, _ := unversioned.Find(bson.M{"number": bson.M{"$gte": 10}}).Count(); count != 2 {
+		t.Error("DB: removed document was not stored again", count)
+	}
+}
+
+//failingBulkCollection fails every bulk operation as a whole, e.g. like a lost connection
+type failingBulkCollection struct {
+	BackendCollection
+}
+
+func (self *failingBulkCollection) Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error) {
+
+	return nil, errTestNetwork
+}
+
+func TestBulkFailure(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+	existing := saveTestModel(t, db, "Existing", 0)
+
+	Test.collection = &failingBulkCollection{Test.collection}
+
+	existing.Number = 1
+	documents := append(bulkTestModels("Alpha"), existing)
+
+	if err := Test.SaveAll(documents, false); err != errTestNetwork {
+		t.Fatal("DB: expected error of the bulk operation", err)
+	}
+
+	//like a failed Save, the documents are reset for a retry
+	if len(documents[0].Id) > 0 || !existing.Id.Valid() || !existing.IsDirty() {
+		t.Error("DB: documents were not reset after the failed bulk operation", documents[0].Id)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"text/template"
+)
+
+//rangeOperator is a filter method which is generated for ordered types (numbers and times)
+type rangeOperator struct {
+	Suffix      string
+	Operator    string
+	Description string
+}
+
+var rangeOperators = []rangeOperator{
+	{"Gt", "$gt", "is greater than"},
+	{"Gte", "$gte", "is greater than or equal to"},
+	{"Lt", "$lt", "is less than"},
+	{"Lte", "$lte", "is less than or equal to"},
+}
+
+var codeTemplate = template.Must(template.New("code").Funcs(template.FuncMap{
+	"rangeOperators": func() []rangeOperator { return rangeOperators },
+}).Parse(`// Code generated by mongodm-gen. DO NOT EDIT.
+
+package {{.Name}}
+
+import (
+{{- range .StandardImports}}
+	{{.}}
+{{- end}}
+{{if .StandardImports}}
+{{end}}
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $model := .Models}}
+// Document keys of {{.Name}}
+const (
+{{- range .Fields}}
+	{{$model.Name}}Field{{.Name}} = "{{.Key}}"
+{{- end}}
+)
+
+// {{.Name}}Filter builds a filter for {{.Name}} documents, e.g. Find(New{{.Name}}Filter().{{(index .Fields 0).Name}}(id))
+type {{.Name}}Filter bson.M
+
+// New{{.Name}}Filter returns an empty filter for {{.Name}} documents
+func New{{.Name}}Filter() {{.Name}}Filter {
+
+	return {{.Name}}Filter{}
+}
+
+// M returns the filter as bson.M
+func (self {{.Name}}Filter) M() bson.M {
+
+	return bson.M(self)
+}
+
+// where adds a condition, several conditions of the same key are combined
+func (self {{.Name}}Filter) where(key string, operator string, value interface{}) {{.Name}}Filter {
+
+	conditions, ok := self[key].(bson.M)
+
+	if !ok {
+		conditions = bson.M{}
+		self[key] = conditions
+	}
+
+	conditions[operator] = value
+
+	return self
+}
+{{range .Fields}}
+// {{.Name}}Exists matches documents with or without the key {{.Key}}
+func (self {{$model.Name}}Filter) {{.Name}}Exists(exists bool) {{$model.Name}}Filter {
+
+	return self.where({{$model.Name}}Field{{.Name}}, "$exists", exists)
+}
+{{- if .Type}}
+
+// {{.Name}} matches documents whose {{.Key}} equals (or contains) the value
+func (self {{$model.Name}}Filter) {{.Name}}(value {{.Type}}) {{$model.Name}}Filter {
+
+	return self.where({{$model.Name}}Field{{.Name}}, "$eq", value)
+}
+
+// {{.Name}}Ne matches documents whose {{.Key}} does not equal (or contain) the value
+func (self {{$model.Name}}Filter) {{.Name}}Ne(value {{.Type}}) {{$model.Name}}Filter {
+
+	return self.where({{$model.Name}}Field{{.Name}}, "$ne", value)
+}
+
+// {{.Name}}In matches documents whose {{.Key}} equals (or contains) one of the values
+func (self {{$model.Name}}Filter) {{.Name}}In(values ...{{.Type}}) {{$model.Name}}Filter {
+
+	return self.where({{$model.Name}}Field{{.Name}}, "$in", values)
+}
+{{- end}}
+{{- if .Ordered}}
+{{- $field := .}}
+{{- range rangeOperators}}
+
+// {{$field.Name}}{{.Suffix}} matches documents whose {{$field.Key}} {{.Description}} the value
+func (self {{$model.Name}}Filter) {{$field.Name}}{{.Suffix}}(value {{$field.Type}}) {{$model.Name}}Filter {
+
+	return self.where({{$model.Name}}Field{{$field.Name}}, "{{.Operator}}", value)
+}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Relations}}
+
+// Populated{{.Field}} returns the populated {{.Field}} relation (see: Query.Populate)
+func (self *{{$model.Name}}) Populated{{.Field}}() ({{if .Many}}[]{{end}}*{{.Model}}, error) {
+
+	return {{$.Mongodm}}Related{{if .Many}}Many{{end}}[{{.Model}}](self.{{.Field}})
+}
+{{- end}}
+{{end}}`))
+
+//generate renders the code for all models of the package
+func generate(parsed *modelPackage) ([]byte, error) {
+
+	var buffer bytes.Buffer
+
+	if err := codeTemplate.Execute(&buffer, parsed); err != nil {
+		return nil, err
+	}
+
+	code, err := format.Source(buffer.Bytes())
+
+	if err != nil {
+		return nil, fmt.Errorf("mongodm-gen: generated invalid code: %v", err)
+	}
+
+	return code, nil
+}
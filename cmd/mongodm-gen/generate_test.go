@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGolden(t *testing.T) {
+
+	parsed, err := parsePackage(filepath.Join("testdata", "models"), "mongodm_gen.go", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := generate(parsed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "models.golden")
+
+	if *update {
+
+		if err := os.WriteFile(golden, code, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(code, expected) {
+		t.Errorf("generated code differs from %v, run go test -update to accept the changes:\n%s", golden, code)
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+
+	parsed, err := parsePackage(filepath.Join("testdata", "models"), "mongodm_gen.go", []string{"Message"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Models) != 1 || parsed.Models[0].Name != "Message" {
+		t.Fatal("unexpected models", parsed.Models)
+	}
+
+	//the related user model was not selected, so no accessor can be generated
+	if len(parsed.Models[0].Relations) != 0 {
+		t.Error("unexpected relation accessors", parsed.Models[0].Relations)
+	}
+
+	if _, err := parsePackage(filepath.Join("testdata", "models"), "mongodm_gen.go", []string{"Address"}); err == nil || !strings.Contains(err.Error(), "no types embedding DocumentBase") {
+		t.Error("expected error for a type without DocumentBase", err)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+mongodm-gen generates typed helpers for all models of a package (struct types which embed mongodm.DocumentBase):
+
+	- constants for the document keys, e.g. UserFieldFirstName = "firstname"
+	- filter builders, e.g. NewUserFilter().FirstName("Max").CreatedAtGte(since)
+	- accessors for populated relations of models in the same package, e.g. user.PopulatedMessages()
+
+Add a go:generate comment to one file of the package and run "go generate":
+
+	//go:generate go run github.com/zebresel-com/mongodm/cmd/mongodm-gen
+
+Afterwards the generated filters can be passed to all find methods:
+
+	users := []*models.User{}
+
+	err := User.Find(models.NewUserFilter().LastName("Mustermann").AgeGte(18)).Sort("-" + models.UserFieldCreatedAt).Exec(&users)
+
+Flags:
+
+	-dir     directory of the package, default is the current directory
+	-output  name of the generated file within the directory, default is "mongodm_gen.go"
+	-type    comma separated list of type names, default are all models of the package
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+
+	directory := flag.String("dir", ".", "directory of the package")
+	output := flag.String("output", "mongodm_gen.go", "name of the generated file")
+	typeNames := flag.String("type", "", "comma separated list of type names, default are all models")
+
+	flag.Parse()
+
+	if err := run(*directory, *output, *typeNames); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(directory string, output string, typeNames string) error {
+
+	var types []string
+
+	if len(typeNames) > 0 {
+		types = strings.Split(typeNames, ",")
+	}
+
+	parsed, err := parsePackage(directory, output, types)
+
+	if err != nil {
+		return err
+	}
+
+	code, err := generate(parsed)
+
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(directory, output), code)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mongodmPath = "github.com/zebresel-com/mongodm"
+	bsonPath    = "gopkg.in/mgo.v2/bson"
+)
+
+//modelPackage contains all models of a package which are passed to the template
+type modelPackage struct {
+	Name    string
+	Mongodm string // qualifier of the mongodm package, e.g. "mongodm." (empty within the mongodm package)
+	StandardImports []string
+	Imports         []string
+	Models          []*model
+}
+
+//model is a struct type which embeds DocumentBase
+type model struct {
+	Name      string
+	Fields    []*field
+	Relations []*relation
+}
+
+//field is a stored struct field of a model
+type field struct {
+	Name    string // name of the struct field
+	Key     string // document key
+	Type    string // value type of the filter methods, empty if no filter methods are generated
+	Ordered bool   // generate range filter methods
+}
+
+//relation is a field with a model tag which refers to a model of the same package
+type relation struct {
+	Field string
+	Model string
+	Many  bool
+}
+
+//documentBaseFields are the fields every model gets from DocumentBase
+var documentBaseFields = []*field{
+	{Name: "Id", Key: "_id", Type: "bson.ObjectId"},
+	{Name: "CreatedAt", Key: "createdAt", Type: "time.Time", Ordered: true},
+	{Name: "UpdatedAt", Key: "updatedAt", Type: "time.Time", Ordered: true},
+	{Name: "Deleted", Key: "deleted", Type: "bool"},
+	{Name: "DeletedAt", Key: "deletedAt", Type: "time.Time", Ordered: true},
+	{Name: "SchemaVersion", Key: "schemaVersion", Type: "int", Ordered: true},
+	{Name: "Version", Key: "version", Type: "int64", Ordered: true},
+}
+
+var orderedTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "time.Time": true,
+}
+
+/*
+parsePackage reads all go files of a directory (except tests and the output file) and collects the struct types which
+embed DocumentBase. Only the given types are collected if types is not empty.
+*/
+func parsePackage(directory string, output string, types []string) (*modelPackage, error) {
+
+	fileNames, err := filepath.Glob(filepath.Join(directory, "*.go"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileSet := token.NewFileSet()
+	parsed := &modelPackage{}
+	imports := map[string]string{} // package name -> import path of all types used in filters
+	relations := map[*model][]*relationCandidate{}
+
+	for _, fileName := range fileNames {
+
+		if strings.HasSuffix(fileName, "_test.go") || filepath.Base(fileName) == filepath.Base(output) {
+			continue
+		}
+
+		file, err := parser.ParseFile(fileSet, fileName, nil, parser.ParseComments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(parsed.Name) > 0 && parsed.Name != file.Name.Name {
+			return nil, fmt.Errorf("mongodm-gen: found packages %v and %v in %v", parsed.Name, file.Name.Name, directory)
+		}
+
+		parsed.Name = file.Name.Name
+		fileImports := importNames(file)
+
+		for _, declaration := range file.Decls {
+
+			genDecl, ok := declaration.(*ast.GenDecl)
+
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+
+				if !ok || typeSpec.TypeParams != nil || !embedsDocumentBase(structType, fileImports) {
+					continue
+				}
+
+				if len(types) > 0 && !contains(types, typeSpec.Name.Name) {
+					continue
+				}
+
+				current := &model{Name: typeSpec.Name.Name, Fields: append([]*field{}, documentBaseFields...)}
+
+				for _, structField := range structType.Fields.List {
+
+					// embedded fields (e.g. DocumentBase) have no names
+					for _, name := range structField.Names {
+
+						if !name.IsExported() {
+							continue
+						}
+
+						tag := reflect.StructTag("")
+
+						if structField.Tag != nil {
+
+							value, err := strconv.Unquote(structField.Tag.Value)
+
+							if err != nil {
+								return nil, err
+							}
+
+							tag = reflect.StructTag(value)
+						}
+
+						bsonTag := strings.Split(tag.Get("bson"), ",")
+						key := bsonTag[0]
+
+						if key == "-" || contains(bsonTag[1:], "inline") {
+							continue
+						}
+
+						if len(key) == 0 {
+							key = strings.ToLower(name.Name)
+						}
+
+						current.Fields = append(current.Fields, &field{Name: name.Name, Key: key})
+						currentField := current.Fields[len(current.Fields)-1]
+
+						if modelTag := tag.Get("model"); len(modelTag) > 0 {
+
+							// relations are stored as ids
+							currentField.Type = "bson.ObjectId"
+							relations[current] = append(relations[current], &relationCandidate{name.Name, modelTag, tag.Get("relation") == "1n"})
+
+							continue
+						}
+
+						currentField.Type, currentField.Ordered = filterType(structField.Type)
+
+						if len(currentField.Type) == 0 {
+							continue
+						}
+
+						if err := collectImports(structField.Type, fileImports, imports); err != nil {
+							return nil, err
+						}
+					}
+				}
+
+				parsed.Models = append(parsed.Models, current)
+			}
+		}
+
+		if parsed.Mongodm == "" {
+
+			if name, ok := fileImports.byPath[mongodmPath]; ok {
+				parsed.Mongodm = name + "."
+			}
+		}
+	}
+
+	if len(parsed.Models) == 0 {
+		return nil, fmt.Errorf("mongodm-gen: no types embedding DocumentBase found in %v", directory)
+	}
+
+	if parsed.Mongodm == "" && parsed.Name != "mongodm" {
+		parsed.Mongodm = "mongodm."
+	}
+
+	//resolve relations to models of the same package
+	for _, current := range parsed.Models {
+
+		for _, candidate := range relations[current] {
+
+			for _, related := range parsed.Models {
+
+				if strings.EqualFold(related.Name, candidate.model) {
+					current.Relations = append(current.Relations, &relation{candidate.field, related.Name, candidate.many})
+					break
+				}
+			}
+		}
+
+		if len(current.Relations) > 0 && len(parsed.Mongodm) > 0 {
+			imports[strings.TrimSuffix(parsed.Mongodm, ".")] = mongodmPath
+		}
+	}
+
+	if path, ok := imports["bson"]; ok && path != bsonPath {
+		return nil, fmt.Errorf("mongodm-gen: package bson (%v) conflicts with %v", path, bsonPath)
+	}
+
+	imports["bson"] = bsonPath
+	imports["time"] = "time"
+
+	for name, path := range imports {
+
+		spec := strconv.Quote(path)
+
+		if name != filepath.Base(path) {
+			spec = name + " " + spec
+		}
+
+		// standard library packages have no domain in the path
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			parsed.Imports = append(parsed.Imports, spec)
+		} else {
+			parsed.StandardImports = append(parsed.StandardImports, spec)
+		}
+	}
+
+	sort.Strings(parsed.StandardImports)
+	sort.Strings(parsed.Imports)
+
+	return parsed, nil
+}
+
+type relationCandidate struct {
+	field string
+	model string
+	many  bool
+}
+
+//fileImports maps the package names of a file to import paths and back
+type fileImports struct {
+	byName map[string]string
+	byPath map[string]string
+}
+
+func importNames(file *ast.File) fileImports {
+
+	names := fileImports{map[string]string{}, map[string]string{}}
+
+	for _, spec := range file.Imports {
+
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+
+		names.byName[name] = path
+		names.byPath[path] = name
+	}
+
+	return names
+}
+
+//embedsDocumentBase reports if the struct embeds mongodm.DocumentBase (or DocumentBase within the mongodm package)
+func embedsDocumentBase(structType *ast.StructType, imports fileImports) bool {
+
+	for _, structField := range structType.Fields.List {
+
+		if len(structField.Names) > 0 {
+			continue
+		}
+
+		switch embedded := structField.Type.(type) {
+		case *ast.Ident:
+
+			if embedded.Name == "DocumentBase" {
+				return true
+			}
+
+		case *ast.SelectorExpr:
+
+			if packageName, ok := embedded.X.(*ast.Ident); ok && embedded.Sel.Name == "DocumentBase" && imports.byName[packageName.Name] == mongodmPath {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+filterType returns the value type of the filter methods for a field type and reports if range filters make sense.
+Lists are filtered by their elements, pointers by the value they point to. No filter methods are generated for
+maps, interfaces, functions and channels.
+*/
+func filterType(expression ast.Expr) (string, bool) {
+
+	switch typedExpression := expression.(type) {
+	case *ast.StarExpr:
+		return filterType(typedExpression.X)
+	case *ast.ArrayType:
+
+		if identifier, ok := typedExpression.Elt.(*ast.Ident); ok && (identifier.Name == "byte" || identifier.Name == "uint8") {
+			return "[]byte", false
+		}
+
+		return filterType(typedExpression.Elt)
+
+	case *ast.Ident, *ast.SelectorExpr:
+
+		name := typeString(typedExpression)
+
+		return name, orderedTypes[name]
+	}
+
+	return "", false
+}
+
+func typeString(expression ast.Expr) string {
+
+	switch typedExpression := expression.(type) {
+	case *ast.Ident:
+		return typedExpression.Name
+	case *ast.SelectorExpr:
+		return typeString(typedExpression.X) + "." + typedExpression.Sel.Name
+	}
+
+	return ""
+}
+
+//collectImports adds the imports of all packages which are referenced by a field type
+func collectImports(expression ast.Expr, names fileImports, imports map[string]string) error {
+
+	var err error
+
+	ast.Inspect(expression, func(node ast.Node) bool {
+
+		selector, ok := node.(*ast.SelectorExpr)
+
+		if !ok {
+			return err == nil
+		}
+
+		if packageName, ok := selector.X.(*ast.Ident); ok {
+
+			path, known := names.byName[packageName.Name]
+
+			if !known {
+				return false
+			}
+
+			if existing, ok := imports[packageName.Name]; ok && existing != path {
+				err = fmt.Errorf("mongodm-gen: package name %v is used for %v and %v", packageName.Name, existing, path)
+			}
+
+			imports[packageName.Name] = path
+		}
+
+		return false
+	})
+
+	return err
+}
+
+func contains(values []string, value string) bool {
+
+	for _, current := range values {
+
+		if current == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+//writeFile writes the generated code, an unchanged file is not touched so the build cache stays valid
+func writeFile(fileName string, code []byte) error {
+
+	if existing, err := os.ReadFile(fileName); err == nil && string(existing) == string(code) {
+		return nil
+	}
+
+	return os.WriteFile(fileName, code, 0644)
+}
@@ -0,0 +1,43 @@
+package models
+
+//go:generate go run github.com/zebresel-com/mongodm/cmd/mongodm-gen
+
+import (
+	"time"
+
+	odm "github.com/zebresel-com/mongodm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Address struct {
+	Street string `json:"street" bson:"street"`
+	City   string `json:"city" bson:"city"`
+}
+
+type User struct {
+	odm.DocumentBase `json:",inline" bson:",inline"`
+
+	FirstName string            `json:"firstname" bson:"firstname" required:"true"`
+	LastName  string            `json:"lastname" bson:"lastname"`
+	Email     string            `json:"email" bson:"email,omitempty" index:"unique"`
+	Age       int               `json:"age"`
+	Tags      []string          `json:"tags" bson:"tags"`
+	Nickname  *string           `json:"nickname" bson:"nickname"`
+	LastLogin *time.Time        `json:"lastLogin" bson:"lastLogin"`
+	Address   Address           `json:"address" bson:"address"`
+	Settings  map[string]string `json:"settings" bson:"settings"`
+	Avatar    []byte            `json:"-" bson:"avatar"`
+	Password  string            `json:"-" bson:"-"`
+	Friend    bson.ObjectId     `json:"friend" bson:"friend,omitempty"`
+	Messages  interface{}       `json:"messages" bson:"messages" model:"Message" relation:"1n"`
+	Company   interface{}       `json:"company" bson:"company" model:"billing.Company" relation:"11"`
+
+	internal string
+}
+
+type Message struct {
+	odm.DocumentBase `json:",inline" bson:",inline"`
+
+	Text   string      `json:"text" bson:"text"`
+	Sender interface{} `json:"sender" bson:"sender" model:"user" relation:"11"`
+}
@@ -0,0 +1,252 @@
+package mongodm
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+IsDirty reports whether the document was changed since it was loaded, initialized with Model.New or saved.
+Documents which are not tracked (e.g. a DocumentBase which was not initialized) are always dirty.
+
+For example:
+	if user.IsDirty() {
+		err = user.Save()
+	}
+*/
+func (self *DocumentBase) IsDirty() bool {
+
+	return len(self.ChangedFields()) > 0
+}
+
+/*
+ChangedFields returns the sorted bson paths which were changed since the document was loaded, initialized with
+Model.New or saved. Changes of embedded structs are reported with their full path (e.g. "address.city"), arrays are
+compared as a whole. If the document is not tracked, all keys are returned.
+
+For example:
+	user.Address.City = "Berlin"
+
+	fmt.Println(user.ChangedFields()) //[address.city]
+*/
+func (self *DocumentBase) ChangedFields() []string {
+
+	if self.document == nil {
+		return nil
+	}
+
+	current, err := self.storedForm()
+
+	if err != nil {
+		return nil
+	}
+
+	paths := []string{}
+
+	if self.snapshot == nil {
+
+		for key := range current {
+			paths = append(paths, key)
+		}
+
+	} else {
+
+		set, unset := bson.M{}, bson.M{}
+
+		diffDocuments("", self.snapshot, current, set, unset)
+
+		for _, changes := range []bson.M{set, unset} {
+
+			for path := range changes {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+//track takes a snapshot of the stored form of the document, Save then only writes the fields which differ from it
+func (self *DocumentBase) track() {
+
+	if self.document == nil {
+		return
+	}
+
+	//an untracked document is stored completely, so an error is no problem here
+	self.snapshot, _ = self.storedForm()
+}
+
+//storedForm serializes the document like Save does, populated relations are reduced to their object ids
+func (self *DocumentBase) storedForm() (bson.M, error) {
+
+	raw, err := self.rawDocument()
+
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := documentMap(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	documentSchema, err := schemaOf(reflect.TypeOf(self.document))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range documentSchema.fields {
+
+		if value, ok := document[field.bsonName]; ok && field.isRelation() {
+			document[field.bsonName] = self.hidden.restoreValue(field.name, relationIds(value))
+		}
+	}
+
+	return document, nil
+}
+
+//relationIds replaces populated documents of a relation value by their ids
+func relationIds(value interface{}) interface{} {
+
+	switch typed := value.(type) {
+
+	case bson.M:
+		return typed["_id"]
+
+	case []interface{}:
+
+		ids := make([]interface{}, len(typed))
+
+		for index, element := range typed {
+			ids[index] = relationIds(element)
+		}
+
+		return ids
+	}
+
+	return value
+}
+
+//restoreValue inserts the hidden ids into a serialized id list, like Save does (see: hiddenIds.restore)
+func (self hiddenIds) restoreValue(field string, value interface{}) interface{} {
+
+	values, ok := value.([]interface{})
+
+	if !ok || len(self[field]) == 0 {
+		return value
+	}
+
+	ids := make([]bson.ObjectId, 0, len(values))
+
+	for _, element := range values {
+
+		id, ok := element.(bson.ObjectId)
+
+		if !ok {
+			return value
+		}
+
+		ids = append(ids, id)
+	}
+
+	restored := []interface{}{}
+
+	for _, id := range self.restore(field, ids) {
+		restored = append(restored, id)
+	}
+
+	return restored
+}
+
+/*
+write stores only the changed paths of the document ($set / $unset), or the whole document if it is not tracked or
+replace is set. It reports whether the filter matched a document.
+*/
+func (self *DocumentBase) write(ctx context.Context, filter bson.M, raw bson.Raw, replace bool) (bool, error) {
+
+	if replace || self.snapshot == nil {
+		return self.model.collection.Replace(ctx, filter, raw)
+	}
+
+	update, err := self.changes(raw)
+
+	if err != nil {
+		return false, err
+	}
+
+	//nothing changed, the document only has to exist
+	if len(update) == 0 {
+
+		count, err := self.model.collection.Count(ctx, filter)
+
+		return count > 0, err
+	}
+
+	return self.model.collection.Update(ctx, filter, update)
+}
+
+//changes returns the update operators ($set / $unset) for the paths of the serialized document which differ from the snapshot
+func (self *DocumentBase) changes(raw bson.Raw) (bson.M, error) {
+
+	current, err := documentMap(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	set, unset := bson.M{}, bson.M{}
+
+	diffDocuments("", self.snapshot, current, set, unset)
+
+	//fields which were not selected on load have zero values, so they must not be written
+	if self.projection != nil {
+		self.projection.restrict(set)
+		self.projection.restrict(unset)
+	}
+
+	update := bson.M{}
+
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return update, nil
+}
+
+//diffDocuments collects the paths of changed values in set and the paths of removed keys in unset, embedded documents are compared recursively
+func diffDocuments(prefix string, previous bson.M, current bson.M, set bson.M, unset bson.M) {
+
+	for key, value := range current {
+
+		path := prefix + key
+		previousValue, found := previous[key]
+
+		previousDocument, previousIsDocument := previousValue.(bson.M)
+		currentDocument, currentIsDocument := value.(bson.M)
+
+		if found && previousIsDocument && currentIsDocument {
+			diffDocuments(path+".", previousDocument, currentDocument, set, unset)
+		} else if !found || !reflect.DeepEqual(previousValue, value) {
+			set[path] = value
+		}
+	}
+
+	for key := range previous {
+
+		if _, found := current[key]; !found {
+			unset[prefix+key] = ""
+		}
+	}
+}
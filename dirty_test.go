@@ -0,0 +1,145 @@
+package mongodm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestDirtyAddress struct {
+	City string `json:"city" bson:"city"`
+	Zip  string `json:"zip" bson:"zip"`
+}
+
+type TestDirtyModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name     string            `json:"name" bson:"name"`
+	Nickname string            `json:"nickname" bson:"nickname,omitempty"`
+	Tags     []string          `json:"tags" bson:"tags"`
+	Address  *TestDirtyAddress `json:"address" bson:"address"`
+}
+
+func TestDirtyTracking(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestDirtyModel{}, "dirty"); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestDirtyModel")
+	ctx := context.Background()
+
+	document := &TestDirtyModel{}
+
+	model.New(document)
+
+	if document.IsDirty() {
+		t.Error("DB: new document is dirty", document.ChangedFields())
+	}
+
+	document.Name = "Max"
+	document.Nickname = "Maxi"
+	document.Address = &TestDirtyAddress{City: "Hamburg", Zip: "20095"}
+
+	if fields := document.ChangedFields(); !reflect.DeepEqual(fields, []string{"address", "name", "nickname"}) {
+		t.Error("DB: unexpected changed fields", fields)
+	}
+
+	if err := document.Save(); err != nil || document.IsDirty() {
+		t.Fatal("DB: document could not be saved", document.ChangedFields(), err)
+	}
+
+	loaded := &TestDirtyModel{}
+
+	if err := model.FindId(document.Id).Exec(loaded); err != nil || loaded.IsDirty() {
+		t.Fatal("DB: loaded document is dirty", loaded.ChangedFields(), err)
+	}
+
+	//another request changes the name meanwhile
+	model.collection.Update(ctx, bson.M{"_id": document.Id}, bson.M{"$set": bson.M{"name": "Moritz"}})
+
+	loaded.Address.City = "Berlin"
+	loaded.Nickname = ""
+	loaded.Tags = []string{"new"}
+
+	if fields := loaded.ChangedFields(); !reflect.DeepEqual(fields, []string{"address.city", "nickname", "tags"}) {
+		t.Error("DB: unexpected changed fields", fields)
+	}
+
+	if err := loaded.Save(); err != nil || loaded.IsDirty() {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	stored := bson.M{}
+	raws, _ := model.collection.Find(ctx, bson.M{"_id": document.Id}, &FindOptions{})
+	raws[0].Unmarshal(&stored)
+
+	if stored["name"] != "Moritz" || stored["address"].(bson.M)["city"] != "Berlin" || stored["address"].(bson.M)["zip"] != "20095" {
+		t.Error("DB: save did not only write the changed fields", stored)
+	}
+
+	if _, found := stored["nickname"]; found {
+		t.Error("DB: removed field was not unset", stored)
+	}
+
+	//a document which does not exist anymore is stored completely
+	model.collection.Remove(ctx, bson.M{"_id": document.Id})
+
+	loaded.Name = "Erika"
+
+	if err := loaded.Save(); err != nil {
+		t.Fatal("DB: removed document could not be saved", err)
+	}
+
+	if count, _ := model.Find(bson.M{"name": "Erika", "address.zip": "20095"}).Count(); count != 1 {
+		t.Error("DB: removed document was not stored completely")
+	}
+}
+
+func TestDirtyRelations(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+	TestRelation := db.Model("testrelationmodel")
+
+	relation := &TestRelationModel{}
+
+	TestRelation.New(relation)
+	relation.RelationName = "Child"
+
+	if err := relation.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	testModel := saveTestModel(t, db, "Parent", 1)
+	testModel.Relation11 = relation.Id
+	testModel.Relation1N = []bson.ObjectId{relation.Id}
+
+	if err := testModel.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	loaded := &TestModel{}
+
+	if err := Test.FindId(testModel.Id).Populate("Relation11", "Relation1N").Exec(loaded); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	//populated relations are compared by their ids
+	if loaded.IsDirty() {
+		t.Error("DB: populated document is dirty", loaded.ChangedFields())
+	}
+
+	loaded.Relation1N = []*TestRelationModel{}
+
+	if fields := loaded.ChangedFields(); !reflect.DeepEqual(fields, []string{"relationMany"}) {
+		t.Error("DB: unexpected changed fields", fields)
+	}
+}
@@ -1,36 +1,52 @@
 package mongodm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // This is the base type each model needs for working with the ODM. Of course you can create your own base type but make sure
 // that you implement the IDocumentBase type interface!
 type DocumentBase struct {
-	document   IDocumentBase   `json:"-" bson:"-"`
-	collection *mgo.Collection `json:"-" bson:"-"`
-	connection *Connection     `json:"-" bson:"-"`
+	document   IDocumentBase `json:"-" bson:"-"`
+	model      *Model        `json:"-" bson:"-"`
+	connection *Connection   `json:"-" bson:"-"`
+	snapshot   bson.M        `json:"-" bson:"-"` // stored form of the document when it was loaded or saved (see: IsDirty)
+	projection *projection   `json:"-" bson:"-"` // selector of the query which loaded the document (see: ModelConfig.Projection)
+	hidden     hiddenIds     `json:"-" bson:"-"` // ids of deleted relations which were not populated, written back on save
 
 	Id        bson.ObjectId `json:"id" bson:"_id,omitempty"`
 	CreatedAt time.Time     `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time     `json:"updatedAt" bson:"updatedAt"`
 	Deleted   bool          `json:"-" bson:"deleted"`
+	DeletedAt time.Time     `json:"-" bson:"deletedAt,omitempty"`
+
+	SchemaVersion int   `json:"-" bson:"schemaVersion,omitempty"` // only stored for models with upgrades (see: ModelConfig.Upgrades)
+	Version       int64 `json:"-" bson:"version,omitempty"`       // only stored for models with versioning (see: ModelConfig.Versioning)
+
+	Extras bson.M `json:"-" bson:"-"` // stored keys without struct field, written back on save (see: ModelConfig.KeepExtras)
 }
 
 type m map[string]interface{}
 
-func (self *DocumentBase) SetCollection(collection *mgo.Collection) {
-	self.collection = collection
+//documentBase gives access to the embedded DocumentBase of a document
+type documentBase interface {
+	base() *DocumentBase
+}
+
+func (self *DocumentBase) base() *DocumentBase {
+	return self
+}
+
+func (self *DocumentBase) SetModel(model *Model) {
+	self.model = model
 }
 
 func (self *DocumentBase) SetDocument(document IDocumentBase) {
@@ -86,32 +102,21 @@ func (self *DocumentBase) Validate(Values ...interface{}) (bool, []error) {
 func (self *DocumentBase) DefaultValidate() (bool, []error) {
 
 	documentValue := reflect.ValueOf(self.document).Elem()
-	fieldType := documentValue.Type()
 	validationErrors := make([]error, 0, 0)
 
-	// Iterate all struct fields
-	for fieldIndex := 0; fieldIndex < documentValue.NumField(); fieldIndex++ {
-
-		var minLen int
-		var maxLen int
-		var required bool
-		var err error
-		var fieldValue reflect.Value
+	documentSchema, err := schemaOf(documentValue.Type())
 
-		field := fieldType.Field(fieldIndex)
-		fieldTag := field.Tag
+	if err != nil {
+		return false, append(validationErrors, err)
+	}
 
-		validation := strings.ToLower(fieldTag.Get("validation"))
-		validationName := fieldTag.Get("json")
+	// Iterate all struct fields
+	for _, field := range documentSchema.fields {
 
-		minLenTag := fieldTag.Get("minLen")
-		maxLenTag := fieldTag.Get("maxLen")
-		requiredTag := fieldTag.Get("required")
-		modelTag := fieldTag.Get("model")
-		relationTag := fieldTag.Get("relation") // Reference relation, e.g. one-to-one or one-to-many
+		var fieldValue reflect.Value
 
-		fieldName := fieldType.Field(fieldIndex).Name
-		fieldElem := documentValue.Field(fieldIndex)
+		validationName := field.validationName
+		fieldElem := documentValue.Field(field.index)
 
 		// Get element of field by checking if pointer or copy
 		if fieldElem.Kind() == reflect.Ptr || fieldElem.Kind() == reflect.Interface {
@@ -120,45 +125,9 @@ func (self *DocumentBase) DefaultValidate() (bool, []error) {
 			fieldValue = fieldElem
 		}
 
-		if len(minLenTag) > 0 {
-
-			minLen, err = strconv.Atoi(minLenTag)
-
-			if err != nil {
-				panic("Check your minLen tag - must be numeric")
-			}
-		}
-
-		if len(maxLenTag) > 0 {
-
-			maxLen, err = strconv.Atoi(maxLenTag)
-
-			if err != nil {
-				panic("Check your maxLen tag - must be numeric")
-			}
-		}
-
-		if len(requiredTag) > 0 {
-
-			required, err = strconv.ParseBool(requiredTag)
-
-			if err != nil {
-				panic("Check your required tag - must be boolean")
-			}
-
-		}
-
-		splittedFieldName := strings.Split(validationName, ",")
-
-		validationName = splittedFieldName[0]
-
-		if validationName == "-" {
-			validationName = strings.ToLower(fieldName)
-		}
-
-		if len(relationTag) > 0 && fieldValue.Kind() == reflect.Slice && relationTag != REL_1N {
+		if len(field.relation) > 0 && fieldValue.Kind() == reflect.Slice && field.relation != REL_1N {
 			self.AppendError(&validationErrors, L("validation.field_invalid_relation1n", validationName))
-		} else if fieldValue.Kind() != reflect.Slice && relationTag == REL_1N {
+		} else if fieldValue.Kind() != reflect.Slice && field.relation == REL_1N {
 			self.AppendError(&validationErrors, L("validation.field_invalid_relation11", validationName))
 		}
 
@@ -183,7 +152,7 @@ func (self *DocumentBase) DefaultValidate() (bool, []error) {
 			isSet = !reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(reflect.TypeOf(fieldValue.Interface())).Interface())
 		}
 
-		if required && !isSet {
+		if field.required && !isSet {
 
 			self.AppendError(&validationErrors, L("validation.field_required", validationName))
 		}
@@ -191,30 +160,26 @@ func (self *DocumentBase) DefaultValidate() (bool, []error) {
 		if fieldValue.IsValid() {
 			if stringFieldValue, ok := fieldValue.Interface().(string); ok {
 
-				// Regex to match a regex
-				regex := regexp.MustCompile(`\/((?)(?:[^\r\n\[\/\\]|\\.|\[(?:[^\r\n\]\\]|\\.)*\])+)\/((?:g(?:im?|m)?|i(?:gm?|m)?|m(?:gi?|i)?)?)`)
-				isRegex := regex.MatchString(validation)
+				if isSet && field.minLen > 0 && len(stringFieldValue) < field.minLen {
 
-				if isSet && minLen > 0 && len(stringFieldValue) < minLen {
+					self.AppendError(&validationErrors, L("validation.field_minlen", validationName, field.minLen))
 
-					self.AppendError(&validationErrors, L("validation.field_minlen", validationName, minLen))
+				} else if isSet && field.maxLen > 0 && len(stringFieldValue) > field.maxLen {
 
-				} else if isSet && maxLen > 0 && len(stringFieldValue) > maxLen {
-
-					self.AppendError(&validationErrors, L("validation.field_maxlen", validationName, maxLen))
+					self.AppendError(&validationErrors, L("validation.field_maxlen", validationName, field.maxLen))
 				}
 
-				if isSet && isRegex && !validateRegexp(validation, stringFieldValue) {
+				if isSet && field.regex != nil && !field.regex.MatchString(stringFieldValue) {
 
 					self.AppendError(&validationErrors, L("validation.field_invalid", validationName))
 				}
 
-				if isSet && validation == "email" && !validateEmail(stringFieldValue) {
+				if isSet && field.validation == "email" && !validateEmail(stringFieldValue) {
 
 					self.AppendError(&validationErrors, L("validation.field_invalid", validationName))
 				}
 
-				if len(modelTag) > 0 {
+				if field.isRelation() {
 
 					if !isSet || !bson.IsObjectIdHex(stringFieldValue) {
 
@@ -312,15 +277,23 @@ func (self *DocumentBase) Update(content interface{}) (error, map[string]interfa
 	return nil, nil
 }
 
-// Calling this method will not remove the object from the database. Instead the deleted flag is set to true.
-// So you can use bson.M{"deleted":false} in your query to filter those documents.
+// Calling this method will not remove the object from the database. Instead the deleted flag is set to true and the
+// deletion time is stored in DeletedAt. Deleted documents are skipped by all queries unless WithDeleted or OnlyDeleted is used.
+// Use Restore to revert and HardDelete to remove the document.
 func (self *DocumentBase) Delete() error {
 
+	return self.DeleteContext(context.Background())
+}
+
+//DeleteContext works like Delete but passes the context to the underlying save operation.
+func (self *DocumentBase) DeleteContext(ctx context.Context) error {
+
 	if self.Id.Valid() {
 
 		self.SetDeleted(true)
+		self.DeletedAt = time.Now()
 
-		return self.Save()
+		return self.SaveContext(ctx)
 	}
 
 	return errors.New("Invalid object id")
@@ -368,24 +341,40 @@ For example:
 */
 func (self *DocumentBase) Populate(field ...string) error {
 
-	if self.document == nil || self.collection == nil || self.connection == nil {
+	return self.PopulateContext(context.Background(), field...)
+}
+
+//PopulateContext works like Populate but stops fetching relations as soon as the context is done.
+func (self *DocumentBase) PopulateContext(ctx context.Context, field ...string) error {
+
+	if self.document == nil || self.model == nil || self.connection == nil {
 		panic("You have to initialize your document with *Model.New(document IDocumentBase) before using Populate()!")
 	}
 
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("Populate on collection '%v'", self.model.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
 	query := &Query{
-		collection: self.collection,
+		model:      self.model,
 		connection: self.connection,
 		query:      bson.M{},
 		multiple:   false,
 		populate:   field,
 	}
 
-	return query.runPopulation(reflect.ValueOf(self.document))
+	return query.runPopulation(ctx, reflect.ValueOf(self.document))
 }
 
 /*
 This method saves all changes for a document. Populated relations are getting converted to object ID's / array of object ID's so you dont have to handle this by yourself.
 Use this function also when the document was newly created, if it is not existent the method will call insert. During the save process createdAt and updatedAt gets also automatically persisted.
+An existing document which was loaded or initialized with Model.New is not replaced, only its changed fields are written
+with $set and $unset (see: func (*DocumentBase) ChangedFields).
 
 For example:
 
@@ -402,62 +391,145 @@ For example:
 */
 func (self *DocumentBase) Save() error {
 
-	if self.document == nil || self.collection == nil || self.connection == nil {
+	return self.SaveContext(context.Background())
+}
+
+/*
+SaveContext works like Save but passes the context to the database session. When autosave is activated for a relation,
+the context is also used for saving all children recursively. If the context is done before the document was written,
+the context error is returned.
+*/
+func (self *DocumentBase) SaveContext(ctx context.Context) error {
+
+	if self.document == nil || self.model == nil || self.connection == nil {
 		panic("You have to initialize your document with *Model.New(document IDocumentBase) before using Save()!")
 	}
 
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("Save on collection '%v'", self.model.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := self.checkSave(); err != nil {
+		return err
+	}
+
+	pending, err := self.prepare(ctx, false)
+
+	if err != nil {
+		return err
+	}
+
+	/*
+	 *	Check if the document is new.
+	 * 	If yes -> Create object
+	 * 	If no -> Update object
+	 */
+	if pending.insert {
+		err = self.model.collection.Insert(ctx, pending.raw)
+	} else if self.model.config.Versioning {
+		err = self.writeVersion(ctx, pending.raw, pending.outdated)
+	} else if pending.outdated || self.snapshot == nil {
+		//only the changes are written, upgraded documents are replaced to remove outdated keys
+		err = self.model.collection.UpsertId(ctx, self.Id, pending.raw)
+	} else {
+
+		var updated bool
+
+		if updated, err = self.write(ctx, bson.M{"_id": self.Id}, pending.raw, false); err == nil && !updated {
+			err = self.unmatched(ctx, pending.raw)
+		}
+	}
+
+	if err != nil {
+		pending.rollback()
+	} else {
+		err = pending.written()
+	}
+
+	return err
+}
+
+//checkSave checks whether the document may be saved, before anything of it is changed
+func (self *DocumentBase) checkSave() error {
+
+	if self.projection != nil && self.model.config.Projection == ProjectionRefuse {
+		return self.projectionError("the model refuses to save partial documents")
+	}
+
 	// Validate document first
 
 	if valid, issues := self.document.Validate(); !valid {
 		return &ValidationError{&QueryError{"Document could not be validated"}, issues}
 	}
 
-	/*
-	 * "This behavior ensures that writes performed in the old session are necessarily observed
-	 * when using the new session, as long as it was a strong or monotonic session.
-	 * That said, it also means that long operations may cause other goroutines using the
-	 * original session to wait." see: http://godoc.org/labix.org/v2/mgo#Session.Clone
-	 */
+	return nil
+}
+
+//pendingWrite is a document which was prepared for writing (see: func (*DocumentBase) prepare)
+type pendingWrite struct {
+	document      *DocumentBase
+	raw           bson.Raw
+	insert        bool
+	outdated      bool          // the document is stored in an older schema version, so it has to be replaced
+	id            bson.ObjectId // id, version and schema version before the preparation
+	version       int64
+	schemaVersion int
+}
 
-	session := self.connection.Session.Clone()
-	defer session.Close()
+/*
+prepare sets the automatic fields (id, timestamps, schema version and version) and serializes the document with
+the relations reduced to their ids. A document without id is always inserted, insert forces it for documents with a
+manually set id. The relation fields are restored before it returns.
+*/
+func (self *DocumentBase) prepare(ctx context.Context, insert bool) (*pendingWrite, error) {
 
-	collection := session.DB(self.connection.Config.DatabaseName).C(self.collection.Name)
+	pending := &pendingWrite{document: self, insert: insert || len(self.Id) == 0, id: self.Id, version: self.Version, schemaVersion: self.SchemaVersion}
 
 	reflectStruct := reflect.ValueOf(self.document).Elem()
-	fieldType := reflectStruct.Type()
 	bufferRegistry := make(map[reflect.Value]reflect.Value) //used for restoring after fields got serialized - we only save ids when not embedded
 
+	/*
+	 *	Restore fields which were changed
+	 *	for saving progress (object deserialisation),
+	 *	also when the save process is aborted
+	 */
+	defer func() {
+		for field, oldValue := range bufferRegistry {
+			field.Set(oldValue)
+		}
+	}()
+
+	documentSchema, err := schemaOf(reflectStruct.Type())
+
+	if err != nil {
+		return nil, self.connection.fail(err)
+	}
+
 	/*
 	 *	Iterate over all struct fields and determine
 	 *	if there are any relations specified.
 	 */
-	for fieldIndex := 0; fieldIndex < reflectStruct.NumField(); fieldIndex++ {
-
-		modelTag := fieldType.Field(fieldIndex).Tag.Get("model")       //the type which should be referenced
-		relationTag := fieldType.Field(fieldIndex).Tag.Get("relation") //reference relation, e.g. one-to-one or one-to-many
-		autoSaveTag := fieldType.Field(fieldIndex).Tag.Get("autosave") //flag if children of relation get automatically saved
+	for _, schemaField := range documentSchema.fields {
 
 		/*
 		 *	Check if custom model and relation field tag is set,
 		 *  otherwise ignore.
 		 */
-		if len(modelTag) > 0 {
+		if schemaField.isRelation() {
 
 			var fieldValue reflect.Value
-			var autoSave bool
-			var relation string
-
-			field := reflectStruct.Field(fieldIndex)
 
-			// Determine relation type for default initialization
-			if relationTag == REL_11 {
-				relation = REL_11
-			} else if relationTag == REL_1N {
-				relation = REL_1N
-			} else {
-				relation = REL_11 //set one-to-one as default relation
-			}
+			field := reflectStruct.Field(schemaField.index)
+			relation := schemaField.relationType()
+			autoSave := schemaField.autoSave
 
 			// If nil and relation one-to-many -> init field with empty slice of object ids and continue loop
 			if field.IsNil() {
@@ -469,13 +541,6 @@ func (self *DocumentBase) Save() error {
 				continue
 			}
 
-			// Determine if relation should be autosaved
-			if autoSaveTag == "true" {
-				autoSave = true
-			} else {
-				autoSave = false //set autosave default to false
-			}
-
 			// Get element of field by checking if pointer or copy
 			if field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
 				fieldValue = field.Elem()
@@ -493,7 +558,7 @@ func (self *DocumentBase) Save() error {
 			if fieldValue.Kind() == reflect.Slice {
 
 				if relation != REL_1N {
-					panic("Relation must be '1n' when using slices!")
+					return nil, self.connection.fail(&RelationError{&QueryError{"Relation must be '1n' when using slices!"}, schemaField.name})
 				}
 
 				sliceLen := fieldValue.Len()
@@ -504,15 +569,18 @@ func (self *DocumentBase) Save() error {
 
 					sliceValue := fieldValue.Index(index)
 
-					err, objectId := self.persistRelation(sliceValue, autoSave)
+					err, objectId := self.persistRelation(ctx, schemaField.name, sliceValue, autoSave)
 
 					if err != nil {
-						return err
+						return nil, err
 					}
 
 					idBuffer[index] = objectId
 				}
 
+				//deleted relations which were not populated must not get lost
+				idBuffer = self.hidden.restore(schemaField.name, idBuffer)
+
 				/*
 				 *	Store the original value and then replace
 				 *  it with the generated id list. The value gets
@@ -526,15 +594,15 @@ func (self *DocumentBase) Save() error {
 			} else if (fieldValue.Kind() == reflect.Ptr && fieldValue.Elem().Kind() == reflect.Struct) || fieldValue.Kind() == reflect.String {
 
 				if relation != REL_11 {
-					panic("Relation must be '11' when using struct or id!")
+					return nil, self.connection.fail(&RelationError{&QueryError{"Relation must be '11' when using struct or id!"}, schemaField.name})
 				}
 
 				var idBuffer bson.ObjectId
 
-				err, objectId := self.persistRelation(fieldValue, autoSave)
+				err, objectId := self.persistRelation(ctx, schemaField.name, fieldValue, autoSave)
 
 				if err != nil {
-					return err
+					return nil, err
 				}
 
 				idBuffer = objectId
@@ -549,65 +617,85 @@ func (self *DocumentBase) Save() error {
 				field.Set(reflect.ValueOf(idBuffer))
 
 			} else {
-				panic(fmt.Sprintf("DB: Following field kinds are supported for saving relations: slice, struct, string. You used %v", fieldValue.Kind()))
+				return nil, self.connection.fail(&RelationError{&QueryError{fmt.Sprintf("DB: Following field kinds are supported for saving relations: slice, struct, string. You used %v", fieldValue.Kind())}, schemaField.name})
 			}
 
 		}
 
 	}
 
-	var err error
-
 	now := time.Now()
 
-	/*
-	 *	Check if Object ID is already set.
-	 * 	If yes -> Update object
-	 * 	If no -> Create object
-	 */
-	if len(self.Id) == 0 {
-
-		self.SetCreatedAt(now)
-		self.SetUpdatedAt(now)
+	//the document was upgraded on load (or is new), so it is stored in the current schema version
+	pending.outdated = self.SchemaVersion < self.model.SchemaVersion()
 
-		self.SetId(bson.NewObjectId())
-
-		err = collection.Insert(self.document)
+	if pending.outdated && self.projection != nil {
+		return nil, self.projectionError("has to be upgraded")
+	}
 
-		if err != nil {
+	if pending.outdated {
+		self.SchemaVersion = self.model.SchemaVersion()
+	}
 
-			if mgo.IsDup(err) {
-				err = &DuplicateError{&QueryError{fmt.Sprintf("Duplicate key")}}
-			}
-		}
+	self.SetUpdatedAt(now)
 
-	} else {
+	if pending.insert {
 
-		self.SetUpdatedAt(now)
-		_, errs := collection.UpsertId(self.Id, self.document)
+		self.SetCreatedAt(now)
 
-		if errs != nil {
+		if len(self.Id) == 0 {
+			self.SetId(bson.NewObjectId())
+		}
 
-			if mgo.IsDup(errs) {
-				errs = &DuplicateError{&QueryError{fmt.Sprintf("Duplicate key")}}
-			} else {
-				err = errs
-			}
+		if self.model.config.Versioning {
+			self.Version = 1
 		}
+
+	} else if self.model.config.Versioning {
+		self.Version++
 	}
 
 	/*
-	 *	Restore fields which were changed
-	 *	for saving progress (object deserialisation)
+	 * The document gets serialized before it is handed over to the backend, because the
+	 * backend call may outlive the save when the context is done in the meantime.
 	 */
-	for field, oldValue := range bufferRegistry {
-		field.Set(oldValue)
+	raw, err := self.rawDocument()
+
+	if err != nil {
+		pending.rollback()
+		return nil, err
+	}
+
+	pending.raw = raw
+
+	return pending, nil
+}
+
+/*
+rollback resets the id, the version and the schema version of a document whose write failed, so a retry writes it
+like the first attempt (e.g. a new document is inserted again). Save, InsertMany and SaveAll call it for every failed
+write, also if the context was done and the write may have reached the database anyway.
+*/
+func (self *pendingWrite) rollback() {
+
+	self.document.Id = self.id
+	self.document.Version = self.version
+	self.document.SchemaVersion = self.schemaVersion
+}
+
+//written tracks the stored form of a written document (see: func (*DocumentBase) IsDirty)
+func (self *pendingWrite) written() error {
+
+	snapshot, err := documentMap(self.raw)
+
+	if err == nil {
+		self.document.snapshot = snapshot
 	}
 
 	return err
 }
 
-func (self *DocumentBase) persistRelation(value reflect.Value, autoSave bool) (error, bson.ObjectId) {
+func (self *DocumentBase) persistRelation(ctx context.Context, fieldName string, value reflect.Value, autoSave bool) (error, bson.ObjectId) {
 
 	// Detect the type of the value which is stored within the slice
 	switch typedValue := value.Interface().(type) {
@@ -617,7 +705,13 @@ func (self *DocumentBase) persistRelation(value reflect.Value, autoSave bool) (e
 		{
 			// Save children when flag is enabled
 			if autoSave {
-				err := typedValue.Save()
+
+				// children are stored in the database of the parent (see: func (*Connection) Tenant)
+				if child, ok := typedValue.(documentBase); ok {
+					self.connection.adopt(child.base())
+				}
+
+				err := typedValue.SaveContext(ctx)
 
 				if err != nil {
 					return err, bson.ObjectId("")
@@ -627,7 +721,7 @@ func (self *DocumentBase) persistRelation(value reflect.Value, autoSave bool) (e
 			objectId := typedValue.GetId()
 
 			if !objectId.Valid() {
-				panic("DB: Can not persist the relation object because the child was not saved before (invalid id).")
+				return self.connection.fail(&RelationError{&QueryError{"DB: Can not persist the relation object because the child was not saved before (invalid id)."}, fieldName}), bson.ObjectId("")
 			}
 
 			return nil, objectId
@@ -637,7 +731,7 @@ func (self *DocumentBase) persistRelation(value reflect.Value, autoSave bool) (e
 	case bson.ObjectId:
 		{
 			if !typedValue.Valid() {
-				panic("DB: Can not persist the relation object because the child was not saved before (invalid id).")
+				return self.connection.fail(&RelationError{&QueryError{"DB: Can not persist the relation object because the child was not saved before (invalid id)."}, fieldName}), bson.ObjectId("")
 			}
 
 			return nil, typedValue
@@ -654,7 +748,7 @@ func (self *DocumentBase) persistRelation(value reflect.Value, autoSave bool) (e
 
 	default:
 		{
-			panic(fmt.Sprintf("DB: Only type 'bson.ObjectId' and 'IDocumentBase' can be stored in slices. You used %v", value.Interface()))
+			return self.connection.fail(&RelationError{&QueryError{fmt.Sprintf("DB: Only type 'bson.ObjectId' and 'IDocumentBase' can be stored in slices. You used %v", value.Interface())}, fieldName}), bson.ObjectId("")
 		}
 	}
 }
@@ -1,5 +1,7 @@
 package mongodm
 
+import "gopkg.in/mgo.v2/bson"
+
 /*
 err = User.FindId(user.Id, findUser)
 
@@ -45,6 +47,67 @@ type InvalidIdError struct {
 	*QueryError
 }
 
+/*
+SchemaError reports a mismatch between a model definition and its usage, e.g. a wrong result type for Exec
+or a missing model tag. Check it with errors.As:
+
+	var schemaError *mongodm.SchemaError
+
+	if errors.As(err, &schemaError) {
+		//programming error
+	}
+*/
+type SchemaError struct {
+	*QueryError
+}
+
+//RelationError reports an invalid relation value, e.g. a child which was not saved before or a wrong relation kind
+type RelationError struct {
+	*QueryError
+	Field string // name of the relation field
+}
+
+//RegistryError is returned for conflicting registrations and lookups of unregistered models (see: func (*Connection) LookupModel)
+type RegistryError struct {
+	*QueryError
+}
+
+/*
+ConflictError is returned by Save if the document was changed by someone else since it was loaded (see: ModelConfig.Versioning).
+Reload the document, apply the changes again and retry.
+
+For example:
+	var conflictError *mongodm.ConflictError
+
+	if errors.As(err, &conflictError) {
+		fmt.Println("stored version is", conflictError.Current)
+	}
+*/
+type ConflictError struct {
+	*QueryError
+	Id      bson.ObjectId
+	Version int64 // version of the document which could not be saved
+	Current int64 // version which is stored in the database
+}
+
+/*
+BulkError is returned by InsertMany and SaveAll if documents could not be written. Errors contains the error of each
+failed document by its index in the given slice, e.g. a *ValidationError or a *DuplicateError. All other documents
+were written.
+
+For example:
+	if bulkError, ok := err.(*mongodm.BulkError); ok {
+
+		for index, err := range bulkError.Errors {
+			fmt.Println(users[index].Email, err)
+		}
+	}
+*/
+type BulkError struct {
+	*QueryError
+	Errors map[int]error
+}
+
 func (self *QueryError) Error() string {
 	return self.message
 }
@@ -0,0 +1,88 @@
+package mongodm
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSchemaErrors(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	saveTestModel(t, db, "Alpha", 1)
+
+	var schemaError *SchemaError
+
+	if err := Test.Find().Exec(&TestModel{}); !errors.As(err, &schemaError) {
+		t.Error("DB: expected schema error for single result of Find", err)
+	}
+
+	if err := Test.FindOne().Exec(&[]*TestModel{}); !errors.As(err, &schemaError) {
+		t.Error("DB: expected schema error for slice result of FindOne", err)
+	}
+
+	if err := Test.Find().Exec(&[]string{}); !errors.As(err, &schemaError) {
+		t.Error("DB: expected schema error for slice of non document types", err)
+	}
+
+	if err := Test.FindOne().Populate("Unknown").Exec(&TestModel{}); !errors.As(err, &schemaError) {
+		t.Error("DB: expected schema error for unknown population field", err)
+	}
+}
+
+func TestRelationErrors(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	testModel := &TestModel{}
+
+	db.Model("testmodel").New(testModel)
+
+	testModel.Name = "Alpha"
+	testModel.RequiredField = "Test"
+	testModel.Relation11 = &TestRelationModel{} // child was never saved
+	testModel.Relation1N = []bson.ObjectId{}
+
+	var relationError *RelationError
+
+	if err := testModel.Save(); !errors.As(err, &relationError) || relationError.Field != "Relation11" {
+		t.Error("DB: expected relation error for unsaved child", err)
+	}
+
+	if _, ok := testModel.Relation11.(*TestRelationModel); !ok {
+		t.Error("DB: relation field was not restored after the failed save", testModel.Relation11)
+	}
+
+	testModel.Relation11 = 42 // unsupported kind
+
+	if err := testModel.Save(); !errors.As(err, &relationError) || relationError.Field != "Relation11" {
+		t.Error("DB: expected relation error for wrong relation kind", err)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Config.Strict = true
+
+	defer func() {
+
+		r := recover()
+
+		if err, ok := r.(error); !ok {
+			t.Error("DB: expected panic with schema error in strict mode", r)
+		} else if _, ok := err.(*SchemaError); !ok {
+			t.Error("DB: expected panic with schema error in strict mode", err)
+		}
+	}()
+
+	db.Model("testmodel").Find().Exec(&TestModel{})
+}
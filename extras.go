@@ -0,0 +1,115 @@
+package mongodm
+
+import (
+	"reflect"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+captureExtras stores all keys of the raw document which have no struct field in DocumentBase.Extras (see: ModelConfig.KeepExtras),
+so fields of other services or newer versions of the application survive a save of this document.
+*/
+func (self *Model) captureExtras(document reflect.Value, raw bson.Raw) error {
+
+	tracked, ok := document.Interface().(documentBase)
+
+	if !self.config.KeepExtras || !ok {
+		return nil
+	}
+
+	documentSchema, err := schemaOf(document.Type())
+
+	if err != nil {
+		return err
+	}
+
+	stored, err := documentMap(raw)
+
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]bool{}
+	documentSchema.storedKeys(keys, map[reflect.Type]bool{})
+
+	extras := bson.M{}
+
+	for key, value := range stored {
+
+		if !keys[key] {
+			extras[key] = value
+		}
+	}
+
+	if len(extras) == 0 {
+		extras = nil
+	}
+
+	tracked.base().Extras = extras
+
+	return nil
+}
+
+//storedKeys collects the document keys of all stored fields, the fields of inline structs are collected recursively
+func (self *schema) storedKeys(keys map[string]bool, visited map[reflect.Type]bool) {
+
+	visited[self.documentType] = true
+
+	for _, field := range self.fields {
+
+		structField := self.documentType.Field(field.index)
+
+		// unexported fields are not stored
+		if len(structField.PkgPath) > 0 || field.bsonName == "-" {
+			continue
+		}
+
+		if field.inline {
+
+			fieldType := structField.Type
+
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			if fieldType.Kind() != reflect.Struct || visited[fieldType] {
+				continue
+			}
+
+			if inlineSchema, err := schemaOf(fieldType); err == nil {
+				inlineSchema.storedKeys(keys, visited)
+			}
+
+			continue
+		}
+
+		keys[field.bsonName] = true
+	}
+}
+
+//rawDocument serializes the document like rawDocument and adds the extras which have no struct field
+func (self *DocumentBase) rawDocument() (bson.Raw, error) {
+
+	raw, err := rawDocument(self.document)
+
+	if err != nil || len(self.Extras) == 0 {
+		return raw, err
+	}
+
+	document, err := documentMap(raw)
+
+	if err != nil {
+		return bson.Raw{}, err
+	}
+
+	for key, value := range self.Extras {
+
+		//the struct field wins
+		if _, found := document[key]; !found {
+			document[key] = value
+		}
+	}
+
+	return rawDocument(document)
+}
@@ -0,0 +1,62 @@
+package mongodm
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestExtrasModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name string `json:"name" bson:"name"`
+}
+
+func TestKeepExtras(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestExtrasModel{}, "extras", &ModelConfig{KeepExtras: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestExtrasModel")
+	ctx := context.Background()
+	id := bson.NewObjectId()
+
+	//another service stores additional fields
+	model.collection.Insert(ctx, bson.M{"_id": id, "name": "Max", "score": 42, "profile": bson.M{"color": "blue"}})
+
+	document := &TestExtrasModel{}
+
+	if err := model.FindId(id).Exec(document); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	if len(document.Extras) != 2 || document.Extras["score"] != 42 || document.IsDirty() {
+		t.Fatal("DB: unexpected extras", document.Extras, document.ChangedFields())
+	}
+
+	//a replacement keeps the extras, too
+	document.snapshot = nil
+	document.Name = "Moritz"
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	if count, _ := model.Find(bson.M{"name": "Moritz", "score": 42, "profile.color": "blue"}).Count(); count != 1 {
+		t.Error("DB: extras were not written back")
+	}
+
+	//models without the option do not capture extras
+	documents := []*TestModel{}
+
+	db.Model("testmodel").collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "name": "Test", "score": 1})
+
+	if err := db.Model("testmodel").Find().Exec(&documents); err != nil || len(documents) != 1 || documents[0].Extras != nil {
+		t.Error("DB: unexpected extras", documents, err)
+	}
+}
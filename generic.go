@@ -0,0 +1,252 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//DocumentPointer is satisfied by pointers to document types, e.g. *User if User embeds DocumentBase
+type DocumentPointer[T any] interface {
+	*T
+	IDocumentBase
+}
+
+/*
+TypedModel is a type-safe view on a registered model. Queries of a typed model return the document type directly,
+so result type mismatches are reported by the compiler instead of Exec. All other model methods (e.g. CreateIndex)
+are available through the embedded *Model.
+
+For example:
+	users, err := mongodm.ModelOf[User](connection).Find(bson.M{"firstname": "Max"}).Populate("Messages").All(ctx)
+*/
+type TypedModel[T any, PT DocumentPointer[T]] struct {
+	*Model
+}
+
+//TypedQuery is returned by the find methods of a TypedModel and executed with All or One.
+type TypedQuery[T any, PT DocumentPointer[T]] struct {
+	query *Query
+}
+
+/*
+ModelOf returns the typed model of a registered document type. Like func (*Connection) Model it panics if the type
+is not registered, use LookupModelOf to get an error instead. Tenant views return the model of their database.
+
+For example:
+	User := mongodm.ModelOf[User](connection)
+
+	user, err := User.FindId(id).One(ctx)
+*/
+func ModelOf[T any, PT DocumentPointer[T]](connection *Connection) *TypedModel[T, PT] {
+
+	model, err := LookupModelOf[T, PT](connection)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return model
+}
+
+//LookupModelOf works like ModelOf but returns a *RegistryError instead of panicking if the type is not registered.
+func LookupModelOf[T any, PT DocumentPointer[T]](connection *Connection) (*TypedModel[T, PT], error) {
+
+	model, err := connection.LookupModel(qualifiedTypeName(reflect.TypeOf((*T)(nil)).Elem()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedModel[T, PT]{model}, nil
+}
+
+/*
+New returns a new document which is initialized for the model (see: func (*Model) New). The optional content is
+applied with func (*DocumentBase) Update.
+
+For example:
+	user, err := mongodm.ModelOf[User](connection).New()
+
+	user.FirstName = "Max"
+
+	err = user.Save()
+*/
+func (self *TypedModel[T, PT]) New(content ...interface{}) (PT, error) {
+
+	document := PT(new(T))
+
+	if err, _ := self.Model.New(document, content...); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+//Find works like func (*Model) Find but returns a typed query.
+func (self *TypedModel[T, PT]) Find(query ...interface{}) *TypedQuery[T, PT] {
+
+	return &TypedQuery[T, PT]{self.Model.Find(query...)}
+}
+
+//FindOne works like func (*Model) FindOne but returns a typed query.
+func (self *TypedModel[T, PT]) FindOne(query ...interface{}) *TypedQuery[T, PT] {
+
+	return &TypedQuery[T, PT]{self.Model.FindOne(query...)}
+}
+
+//FindId works like func (*Model) FindId but returns a typed query.
+func (self *TypedModel[T, PT]) FindId(id bson.ObjectId) *TypedQuery[T, PT] {
+
+	return &TypedQuery[T, PT]{self.Model.FindId(id)}
+}
+
+//See: func (*Query) Select
+func (self *TypedQuery[T, PT]) Select(selector interface{}) *TypedQuery[T, PT] {
+
+	self.query.Select(selector)
+	return self
+}
+
+//See: func (*Query) Sort
+func (self *TypedQuery[T, PT]) Sort(fields ...string) *TypedQuery[T, PT] {
+
+	self.query.Sort(fields...)
+	return self
+}
+
+//See: func (*Query) Limit
+func (self *TypedQuery[T, PT]) Limit(limit int) *TypedQuery[T, PT] {
+
+	self.query.Limit(limit)
+	return self
+}
+
+//See: func (*Query) Skip
+func (self *TypedQuery[T, PT]) Skip(skip int) *TypedQuery[T, PT] {
+
+	self.query.Skip(skip)
+	return self
+}
+
+//See: func (*Query) Mode
+func (self *TypedQuery[T, PT]) Mode(mode Mode) *TypedQuery[T, PT] {
+
+	self.query.Mode(mode)
+	return self
+}
+
+//See: func (*Query) WithDeleted
+func (self *TypedQuery[T, PT]) WithDeleted() *TypedQuery[T, PT] {
+
+	self.query.WithDeleted()
+	return self
+}
+
+//See: func (*Query) OnlyDeleted
+func (self *TypedQuery[T, PT]) OnlyDeleted() *TypedQuery[T, PT] {
+
+	self.query.OnlyDeleted()
+	return self
+}
+
+//See: func (*Query) Populate, use Related and RelatedMany to read the populated fields.
+func (self *TypedQuery[T, PT]) Populate(fields ...string) *TypedQuery[T, PT] {
+
+	self.query.Populate(fields...)
+	return self
+}
+
+//Count returns the number of matching documents, see: func (*Query) CountContext
+func (self *TypedQuery[T, PT]) Count(ctx context.Context) (int, error) {
+
+	return self.query.CountContext(ctx)
+}
+
+/*
+All returns all matching documents. Unlike Exec, no documents are no error but an empty slice.
+
+For example:
+	users, err := mongodm.ModelOf[User](connection).Find().Sort("-createdAt").Limit(10).All(ctx)
+*/
+func (self *TypedQuery[T, PT]) All(ctx context.Context) ([]PT, error) {
+
+	query := *self.query
+	query.multiple = true
+
+	documents := []PT{}
+
+	if err := query.ExecContext(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+/*
+One returns the first matching document. Like Exec, a *NotFoundError is returned if no document matches.
+
+For example:
+	user, err := mongodm.ModelOf[User](connection).FindOne(bson.M{"email": email}).One(ctx)
+
+	if _, ok := err.(*mongodm.NotFoundError); ok {
+		//no records were found
+	}
+*/
+func (self *TypedQuery[T, PT]) One(ctx context.Context) (PT, error) {
+
+	query := *self.query
+	query.multiple = false
+
+	document := PT(new(T))
+
+	if err := query.ExecContext(ctx, document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+/*
+Related returns the document of a populated one-to-one relation field. Nil is returned for an empty relation,
+a *RelationError if the field was not populated or stores another type.
+
+For example:
+	user, err := mongodm.ModelOf[User](connection).FindId(id).Populate("Address").One(ctx)
+
+	address, err := mongodm.Related[Address](user.Address)
+*/
+func Related[T any, PT DocumentPointer[T]](relation interface{}) (PT, error) {
+
+	switch typedRelation := relation.(type) {
+	case nil:
+		return nil, nil
+	case PT:
+		return typedRelation, nil
+	}
+
+	return nil, &RelationError{&QueryError{fmt.Sprintf("DB: Relation of type '%T' is no populated '%T'", relation, PT(nil))}, ""}
+}
+
+/*
+RelatedMany returns the documents of a populated one-to-many relation field. An empty slice is returned for an
+empty relation, a *RelationError if the field was not populated or stores another type.
+
+For example:
+	user, err := mongodm.ModelOf[User](connection).FindId(id).Populate("Messages").One(ctx)
+
+	messages, err := mongodm.RelatedMany[Message](user.Messages)
+*/
+func RelatedMany[T any, PT DocumentPointer[T]](relation interface{}) ([]PT, error) {
+
+	switch typedRelation := relation.(type) {
+	case nil:
+		return []PT{}, nil
+	case []PT:
+		return typedRelation, nil
+	}
+
+	return nil, &RelationError{&QueryError{fmt.Sprintf("DB: Relation of type '%T' is no populated '%T'", relation, []PT(nil))}, ""}
+}
@@ -0,0 +1,96 @@
+package mongodm
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestTypedModel(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	relations := ModelOf[TestRelationModel](db)
+	models := ModelOf[TestModel](db)
+
+	relation, err := relations.New(map[string]interface{}{"relationName": "Child"})
+
+	if err != nil || relation.RelationName != "Child" {
+		t.Fatal("DB: typed document could not be created", err)
+	}
+
+	if err := relation.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	for _, name := range []string{"Max", "Moritz"} {
+
+		document, _ := models.New()
+
+		document.Name = name
+		document.RequiredField = "Test"
+		document.Relation11 = relation.Id
+		document.Relation1N = []bson.ObjectId{relation.Id}
+
+		if err := document.Save(); err != nil {
+			t.Fatal("DB: creation error", err)
+		}
+	}
+
+	documents, err := models.Find().Sort("-name").Populate("Relation11", "Relation1N").All(ctx)
+
+	if err != nil || len(documents) != 2 || documents[0].Name != "Moritz" {
+		t.Fatal("DB: typed query returned unexpected documents", documents, err)
+	}
+
+	if populated, err := Related[TestRelationModel](documents[0].Relation11); err != nil || populated.RelationName != "Child" {
+		t.Error("DB: one-to-one relation was not populated", err)
+	}
+
+	if populated, err := RelatedMany[TestRelationModel](documents[0].Relation1N); err != nil || len(populated) != 1 || populated[0].RelationName != "Child" {
+		t.Error("DB: one-to-many relation was not populated", err)
+	}
+
+	if _, err := Related[TestModel](documents[0].Relation11); err == nil {
+		t.Error("DB: expected relation error for a wrong relation type")
+	}
+
+	document, err := models.FindOne(bson.M{"name": "Max"}).One(ctx)
+
+	if err != nil || document.Name != "Max" {
+		t.Fatal("DB: typed query returned unexpected document", document, err)
+	}
+
+	if _, err := Related[TestRelationModel](document.Relation11); err == nil {
+		t.Error("DB: expected relation error for a field which was not populated")
+	}
+
+	//the typed document is initialized like with Exec
+	document.Name = "Maximilian"
+
+	if err := document.Save(); err != nil {
+		t.Error("DB: typed document could not be saved", err)
+	}
+
+	if _, err := models.FindOne(bson.M{"name": "Max"}).One(ctx); err == nil {
+		t.Error("DB: expected not found error")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("DB: expected not found error", err)
+	}
+
+	if documents, err := models.Find(bson.M{"name": "Nobody"}).All(ctx); err != nil || documents == nil || len(documents) != 0 {
+		t.Error("DB: expected empty result", documents, err)
+	}
+
+	if _, err := LookupModelOf[TestValidatorModel](db); err == nil {
+		t.Error("DB: expected registry error for an unregistered type")
+	}
+
+	if count, _ := ModelOf[TestModel](db.Tenant("other")).Find().Count(ctx); count != 0 {
+		t.Error("DB: typed model of the tenant view uses the wrong database", count)
+	}
+}
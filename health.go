@@ -0,0 +1,371 @@
+package mongodm
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//ConnectionState describes the state of a connection which is passed to the state callback (see: Config.OnStateChange)
+type ConnectionState int
+
+const (
+	StateConnected    ConnectionState = iota // the connection was opened
+	StateDisconnected                        // an operation or health check failed because of a network error
+	StateReconnected                         // the connection is usable again after it was disconnected
+	StateClosed                              // the connection was closed
+)
+
+func (self ConnectionState) String() string {
+
+	switch self {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnected:
+		return "reconnected"
+	case StateClosed:
+		return "closed"
+	}
+
+	return "unknown"
+}
+
+/*
+ReconnectConfig activates the automatic reconnection of a connection (see: Config.Reconnect). As soon as an operation fails
+because of a network error, the session gets refreshed in the background until the database server is reachable again.
+The wait time between two attempts starts with Backoff and is doubled after each failed attempt up to MaxBackoff.
+
+For example:
+	dbConfig := &mongodm.Config{
+		DatabaseHosts: []string{"localhost"},
+		DatabaseName:  "mongodm_sample",
+		Reconnect:     &mongodm.ReconnectConfig{Backoff: time.Second, MaxBackoff: time.Minute},
+		OnStateChange: func(state mongodm.ConnectionState, err error) {
+			log.Printf("database %v: %v", state, err)
+		},
+	}
+*/
+type ReconnectConfig struct {
+	Backoff     time.Duration // wait time before the first attempt, default is 500 milliseconds
+	MaxBackoff  time.Duration // maximum wait time between two attempts, default is 30 seconds
+	MaxAttempts int           // attempts until the connection stays disconnected (until the next network error), 0 is unlimited
+}
+
+//Health is the result of a health check (see: func (*Connection) HealthCheck)
+type Health struct {
+	State   ConnectionState
+	Latency time.Duration // round trip time of the ping
+	Err     error         // error of the ping, nil if the database server is reachable
+}
+
+//Healthy reports if the database server was reachable
+func (self Health) Healthy() bool {
+
+	return self.Err == nil
+}
+
+/*
+Ping checks if the database server is reachable. A network error marks the connection as disconnected
+and starts the reconnection if it is activated (see: Config.Reconnect).
+
+For example (readiness probe):
+
+	http.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+
+		if err := connection.Ping(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+*/
+func (self *Connection) Ping(ctx context.Context) error {
+
+	err := self.backend.Ping(ctx)
+
+	self.observe(err)
+
+	return err
+}
+
+//HealthCheck pings the database server and returns the state of the connection together with the round trip time.
+func (self *Connection) HealthCheck(ctx context.Context) Health {
+
+	start := time.Now()
+	err := self.Ping(ctx)
+
+	return Health{
+		State:   self.State(),
+		Latency: time.Since(start),
+		Err:     err,
+	}
+}
+
+//State returns the current state of the connection.
+func (self *Connection) State() ConnectionState {
+
+	root := self.rootConnection()
+
+	root.stateMutex.Lock()
+	defer root.stateMutex.Unlock()
+
+	return root.state
+}
+
+//notify calls the state callback of the connection
+func (self *Connection) notify(state ConnectionState, err error) {
+
+	if self.Config.OnStateChange != nil {
+		self.Config.OnStateChange(state, err)
+	}
+}
+
+/*
+observe checks the result of an operation. A network error marks the connection as disconnected and starts the
+reconnection, a successful operation marks a disconnected connection as reconnected. All other errors are ignored.
+*/
+func (self *Connection) observe(err error) {
+
+	if err != nil && !self.backend.IsNetworkError(err) {
+		return
+	}
+
+	root := self.rootConnection()
+
+	root.stateMutex.Lock()
+
+	if root.state == StateClosed || root.reconnecting {
+		root.stateMutex.Unlock()
+		return
+	}
+
+	if err == nil {
+
+		recovered := root.state == StateDisconnected
+
+		if recovered {
+			root.state = StateReconnected
+		}
+
+		root.stateMutex.Unlock()
+
+		if recovered {
+			root.notify(StateReconnected, nil)
+		}
+
+		return
+	}
+
+	disconnected := root.state != StateDisconnected
+	closing := root.closing
+
+	root.state = StateDisconnected
+	root.reconnecting = root.Config.Reconnect != nil
+
+	root.stateMutex.Unlock()
+
+	if disconnected {
+		root.notify(StateDisconnected, err)
+	}
+
+	if root.Config.Reconnect != nil {
+		go root.reconnect(*root.Config.Reconnect, closing)
+	}
+}
+
+//reconnect refreshes the session with exponential backoff until the database server is reachable again
+func (self *Connection) reconnect(config ReconnectConfig, closing chan struct{}) {
+
+	backoff := config.Backoff
+
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	maxBackoff := config.MaxBackoff
+
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	defer func() {
+		self.stateMutex.Lock()
+		self.reconnecting = false
+		self.stateMutex.Unlock()
+	}()
+
+	for attempt := 1; config.MaxAttempts == 0 || attempt <= config.MaxAttempts; attempt++ {
+
+		select {
+		case <-closing:
+			return
+		case <-time.After(backoff):
+		}
+
+		timeout := self.Config.DialTimeout
+
+		if timeout <= 0 {
+			timeout = 3 * time.Second
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := self.backend.Refresh(ctx)
+		cancel()
+
+		if err == nil {
+
+			self.stateMutex.Lock()
+
+			if self.state == StateClosed {
+				self.stateMutex.Unlock()
+				return
+			}
+
+			self.state = StateReconnected
+			self.stateMutex.Unlock()
+
+			self.notify(StateReconnected, nil)
+			return
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+/*
+connectionCollection wraps the backend collection of a model, so the connection can observe the result of each
+operation (e.g. to reconnect after network errors).
+*/
+type connectionCollection struct {
+	BackendCollection
+	connection *Connection
+}
+
+func (self *connectionCollection) Find(ctx context.Context, filter interface{}, options *FindOptions) ([]bson.Raw, error) {
+
+	raws, err := self.BackendCollection.Find(ctx, filter, options)
+
+	self.connection.observe(err)
+
+	return raws, err
+}
+
+func (self *connectionCollection) Count(ctx context.Context, filter interface{}) (int, error) {
+
+	n, err := self.BackendCollection.Count(ctx, filter)
+
+	self.connection.observe(err)
+
+	return n, err
+}
+
+func (self *connectionCollection) Insert(ctx context.Context, document interface{}) error {
+
+	err := self.BackendCollection.Insert(ctx, document)
+
+	self.connection.observe(err)
+
+	return err
+}
+
+func (self *connectionCollection) UpsertId(ctx context.Context, id interface{}, document interface{}) error {
+
+	err := self.BackendCollection.UpsertId(ctx, id, document)
+
+	self.connection.observe(err)
+
+	return err
+}
+
+func (self *connectionCollection) Replace(ctx context.Context, filter interface{}, document interface{}) (bool, error) {
+
+	replaced, err := self.BackendCollection.Replace(ctx, filter, document)
+
+	self.connection.observe(err)
+
+	return replaced, err
+}
+
+func (self *connectionCollection) Update(ctx context.Context, filter interface{}, update interface{}) (bool, error) {
+
+	updated, err := self.BackendCollection.Update(ctx, filter, update)
+
+	self.connection.observe(err)
+
+	return updated, err
+}
+
+func (self *connectionCollection) UpdateAll(ctx context.Context, filter interface{}, update interface{}) (int, error) {
+
+	n, err := self.BackendCollection.UpdateAll(ctx, filter, update)
+
+	self.connection.observe(err)
+
+	return n, err
+}
+
+func (self *connectionCollection) FindAndUpdate(ctx context.Context, filter interface{}, update interface{}) (bson.Raw, bool, error) {
+
+	raw, found, err := self.BackendCollection.FindAndUpdate(ctx, filter, update)
+
+	self.connection.observe(err)
+
+	return raw, found, err
+}
+
+func (self *connectionCollection) Bulk(ctx context.Context, operations []BulkOperation, ordered bool) (*BulkResult, error) {
+
+	result, err := self.BackendCollection.Bulk(ctx, operations, ordered)
+
+	self.connection.observe(err)
+
+	return result, err
+}
+
+func (self *connectionCollection) Remove(ctx context.Context, filter interface{}) (int, error) {
+
+	n, err := self.BackendCollection.Remove(ctx, filter)
+
+	self.connection.observe(err)
+
+	return n, err
+}
+
+func (self *connectionCollection) EnsureIndex(ctx context.Context, index Index) error {
+
+	err := self.BackendCollection.EnsureIndex(ctx, index)
+
+	self.connection.observe(err)
+
+	return err
+}
+
+func (self *connectionCollection) Indexes(ctx context.Context) ([]Index, error) {
+
+	indexes, err := self.BackendCollection.Indexes(ctx)
+
+	self.connection.observe(err)
+
+	return indexes, err
+}
+
+func (self *connectionCollection) DropIndex(ctx context.Context, name string) error {
+
+	err := self.BackendCollection.DropIndex(ctx, name)
+
+	self.connection.observe(err)
+
+	return err
+}
+
+func (self *connectionCollection) SetValidator(ctx context.Context, validator bson.M, level ValidationLevel, action ValidationAction) error {
+
+	err := self.BackendCollection.SetValidator(ctx, validator, level, action)
+
+	self.connection.observe(err)
+
+	return err
+}
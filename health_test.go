@@ -0,0 +1,108 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTestNetwork = errors.New("connection reset by peer")
+
+//flakyBackend simulates a database server which is not reachable while down is set
+type flakyBackend struct {
+	Backend
+	mutex sync.Mutex
+	down  bool
+}
+
+func (self *flakyBackend) setDown(down bool) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.down = down
+}
+
+func (self *flakyBackend) Ping(ctx context.Context) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.down {
+		return errTestNetwork
+	}
+
+	return nil
+}
+
+func (self *flakyBackend) Refresh(ctx context.Context) error {
+
+	return self.Ping(ctx)
+}
+
+func (self *flakyBackend) IsNetworkError(err error) bool {
+
+	return err == errTestNetwork
+}
+
+func TestHealthCheckAndReconnect(t *testing.T) {
+
+	states := make(chan ConnectionState, 10)
+
+	db, err := Connect(&Config{
+		Driver:        DriverMemory,
+		Reconnect:     &ReconnectConfig{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		OnStateChange: func(state ConnectionState, err error) { states <- state },
+	})
+
+	if err != nil {
+		t.Fatal("DB: Connection error", err)
+	}
+
+	if state := <-states; state != StateConnected {
+		t.Error("DB: expected connected state", state)
+	}
+
+	backend := &flakyBackend{Backend: db.backend}
+	db.backend = backend
+
+	if health := db.HealthCheck(context.Background()); !health.Healthy() || health.State != StateConnected {
+		t.Error("DB: expected healthy connection", health)
+	}
+
+	backend.setDown(true)
+
+	if health := db.HealthCheck(context.Background()); health.Healthy() || health.State != StateDisconnected {
+		t.Error("DB: expected unhealthy connection", health)
+	}
+
+	if state := <-states; state != StateDisconnected {
+		t.Error("DB: expected disconnected state", state)
+	}
+
+	backend.setDown(false)
+
+	select {
+	case state := <-states:
+
+		if state != StateReconnected {
+			t.Error("DB: expected reconnected state", state)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("DB: connection was not reconnected")
+	}
+
+	if err := db.Ping(context.Background()); err != nil || db.State() != StateReconnected {
+		t.Error("DB: expected reachable connection after reconnection", err, db.State())
+	}
+
+	db.Close()
+	db.Close()
+
+	if state := <-states; state != StateClosed || len(states) > 0 {
+		t.Error("DB: expected a single closed state", state)
+	}
+}
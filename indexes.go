@@ -0,0 +1,313 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//indexDeclaration is a single declaration of an index tag, e.g. index:"compound=name_email,order=-1"
+type indexDeclaration struct {
+	compound string // name of the compound index, empty for a single field index
+	order    int    // 1 or -1
+	unique   bool
+	sparse   bool
+	ttl      time.Duration
+}
+
+/*
+IndexReport is the result of func (*Model) SyncIndexes. Indexes which are not declared or differ from their declaration
+are only dropped if ModelConfig.DropIndexes is set, otherwise they are listed as Obsolete or Conflicting.
+*/
+type IndexReport struct {
+	Created     []string // names of the created indexes
+	Dropped     []string // names of the dropped indexes
+	Obsolete    []string // names of existing indexes which are not declared
+	Conflicting []string // names of existing indexes which differ from their declaration
+}
+
+/*
+parseIndexTag parses the value of an index tag. Options are separated by ",", several indexes of the same field
+by ";" (e.g. index:"unique;compound=name_email"). An empty tag declares an ascending single field index.
+*/
+func parseIndexTag(tag string) ([]indexDeclaration, error) {
+
+	expected := errors.New("a list of unique, sparse, ttl=<seconds>, order=1|-1 or compound=<name>")
+	declarations := []indexDeclaration{}
+
+	for _, declarationTag := range strings.Split(tag, ";") {
+
+		declaration := indexDeclaration{order: 1}
+
+		for _, option := range strings.Split(declarationTag, ",") {
+
+			option = strings.TrimSpace(option)
+			value := ""
+
+			if separator := strings.Index(option, "="); separator >= 0 {
+				option, value = option[:separator], option[separator+1:]
+			}
+
+			switch option {
+			case "":
+			case "unique":
+				declaration.unique = true
+			case "sparse":
+				declaration.sparse = true
+			case "ttl":
+
+				seconds, err := strconv.Atoi(value)
+
+				if err != nil || seconds < 0 {
+					return nil, expected
+				}
+
+				declaration.ttl = time.Duration(seconds) * time.Second
+			case "order":
+
+				if value != "1" && value != "-1" {
+					return nil, expected
+				}
+
+				declaration.order, _ = strconv.Atoi(value)
+			case "compound":
+
+				if len(value) == 0 {
+					return nil, expected
+				}
+
+				declaration.compound = value
+			default:
+				return nil, expected
+			}
+		}
+
+		if len(declaration.compound) > 0 && declaration.ttl > 0 {
+			return nil, errors.New("a single field index if ttl is set")
+		}
+
+		declarations = append(declarations, declaration)
+	}
+
+	return declarations, nil
+}
+
+/*
+schemaIndexes collects the declared indexes of a schema. The keys of a compound index are ordered like the struct fields,
+options of a compound index can be set on any of its fields.
+*/
+func schemaIndexes(documentSchema *schema) ([]Index, error) {
+
+	indexes := []Index{}
+	compounds := map[string]int{} // position of each compound index
+
+	for _, field := range documentSchema.fields {
+
+		for _, declaration := range field.indexes {
+
+			key := field.bsonName
+
+			if declaration.order < 0 {
+				key = "-" + key
+			}
+
+			if len(declaration.compound) == 0 {
+
+				indexes = append(indexes, Index{
+					Name:        indexName([]string{key}),
+					Key:         []string{key},
+					Unique:      declaration.unique,
+					Sparse:      declaration.sparse,
+					ExpireAfter: declaration.ttl,
+				})
+
+				continue
+			}
+
+			position, ok := compounds[declaration.compound]
+
+			if !ok {
+				position = len(indexes)
+				compounds[declaration.compound] = position
+				indexes = append(indexes, Index{Name: declaration.compound})
+			}
+
+			index := &indexes[position]
+
+			index.Key = append(index.Key, key)
+			index.Unique = index.Unique || declaration.unique
+			index.Sparse = index.Sparse || declaration.sparse
+		}
+	}
+
+	names := map[string]bool{}
+
+	for _, index := range indexes {
+
+		if names[index.Name] {
+			return nil, &SchemaError{&QueryError{fmt.Sprintf("DB: Check the index tags of type '%v' - index '%v' is declared twice", documentSchema.documentType.Name(), index.Name)}}
+		}
+
+		names[index.Name] = true
+	}
+
+	return indexes, nil
+}
+
+/*
+SyncIndexes creates all indexes which are declared with index tags but do not exist yet. Indexes of the collection which
+are not declared or differ from their declaration (e.g. a unique option was added) are dropped if ModelConfig.DropIndexes
+is set, otherwise they are only reported. The id index is never touched.
+
+Index tags accept the options unique, sparse, ttl=<seconds>, order=1|-1 and compound=<name>. Fields with the same
+compound name form one index, the key order follows the field order of the struct.
+
+For example:
+	type User struct {
+		mongodm.DocumentBase `bson:",inline"`
+
+		Email     string    `bson:"email" index:"unique"`
+		Name      string    `bson:"name" index:"compound=name_email"`
+		Mail      string    `bson:"mail" index:"compound=name_email,order=-1"`
+		Nickname  string    `bson:"nickname" index:"sparse"`
+		ExpiresAt time.Time `bson:"expiresAt" index:"ttl=3600"`
+	}
+
+	connection.Register(&User{}, "users", &mongodm.ModelConfig{SyncIndexes: true})
+
+	//or later, e.g. in a deployment step
+	report, err := connection.Model("User").SyncIndexes()
+*/
+func (self *Model) SyncIndexes() (*IndexReport, error) {
+
+	return self.SyncIndexesContext(context.Background())
+}
+
+//SyncIndexesContext works like SyncIndexes but is canceled as soon as the given context is done.
+func (self *Model) SyncIndexesContext(ctx context.Context) (*IndexReport, error) {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("SyncIndexes on collection '%v'", self.name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer done()
+
+	collection := self.backendCollection(DefaultMode)
+	existing, err := collection.Indexes(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IndexReport{}
+	matched := make(map[string]bool, len(existing))
+
+	drop := func(name string) error {
+
+		if err := collection.DropIndex(ctx, name); err != nil {
+			return err
+		}
+
+		report.Dropped = append(report.Dropped, name)
+
+		return nil
+	}
+
+	for _, declared := range self.schema.indexes {
+
+		current := matchingIndex(existing, declared)
+
+		if current != nil {
+
+			matched[current.Name] = true
+
+			if sameIndex(*current, declared) {
+				continue
+			}
+
+			if !self.config.DropIndexes {
+				report.Conflicting = append(report.Conflicting, current.Name)
+				continue
+			}
+
+			if err := drop(current.Name); err != nil {
+				return report, err
+			}
+		}
+
+		if err := collection.EnsureIndex(ctx, declared); err != nil {
+			return report, err
+		}
+
+		report.Created = append(report.Created, declared.Name)
+	}
+
+	for _, index := range existing {
+
+		if index.Name == "_id_" || matched[index.Name] {
+			continue
+		}
+
+		if !self.config.DropIndexes {
+			report.Obsolete = append(report.Obsolete, index.Name)
+			continue
+		}
+
+		if err := drop(index.Name); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+//matchingIndex returns the existing index with the name of the declared index or with the same key
+func matchingIndex(existing []Index, declared Index) *Index {
+
+	for position := range existing {
+
+		if existing[position].Name == declared.Name {
+			return &existing[position]
+		}
+	}
+
+	for position := range existing {
+
+		if sameIndexKey(existing[position].Key, declared.Key) {
+			return &existing[position]
+		}
+	}
+
+	return nil
+}
+
+//sameIndex reports if an existing index matches its declaration
+func sameIndex(existing Index, declared Index) bool {
+
+	return existing.Name == declared.Name &&
+		sameIndexKey(existing.Key, declared.Key) &&
+		existing.Unique == declared.Unique &&
+		existing.Sparse == declared.Sparse &&
+		existing.ExpireAfter == declared.ExpireAfter
+}
+
+func sameIndexKey(a []string, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for position := range a {
+
+		if strings.TrimPrefix(a[position], "+") != strings.TrimPrefix(b[position], "+") {
+			return false
+		}
+	}
+
+	return true
+}
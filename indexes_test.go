@@ -0,0 +1,108 @@
+package mongodm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestIndexModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Email     string    `json:"email" bson:"email" index:"unique"`
+	Name      string    `json:"name" bson:"name" index:"compound=name_email"`
+	Mail      string    `json:"mail" bson:"mail" index:"compound=name_email,order=-1"`
+	Nickname  string    `json:"nickname" bson:"nickname" index:"sparse;compound=nickname_name"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt" index:"ttl=3600"`
+}
+
+type TestInvalidIndexModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name string `json:"name" bson:"name" index:"compound=name,ttl=60"`
+}
+
+func TestIndexTags(t *testing.T) {
+
+	documentSchema, err := schemaOf(reflect.TypeOf(&TestIndexModel{}))
+
+	if err != nil {
+		t.Fatal("DB: schema could not be parsed", err)
+	}
+
+	expected := []Index{
+		{Name: "email_1", Key: []string{"email"}, Unique: true},
+		{Name: "name_email", Key: []string{"name", "-mail"}},
+		{Name: "nickname_1", Key: []string{"nickname"}, Sparse: true},
+		{Name: "nickname_name", Key: []string{"nickname"}},
+		{Name: "expiresAt_1", Key: []string{"expiresAt"}, ExpireAfter: time.Hour},
+	}
+
+	if !reflect.DeepEqual(documentSchema.indexes, expected) {
+		t.Errorf("DB: index tags were not parsed correctly: %v", documentSchema.indexes)
+	}
+
+	if _, err := schemaOf(reflect.TypeOf(&TestInvalidIndexModel{})); err == nil {
+		t.Error("DB: expected schema error for ttl on compound index")
+	} else if _, ok := err.(*SchemaError); !ok {
+		t.Error("DB: expected schema error for ttl on compound index", err)
+	}
+}
+
+func TestSyncIndexes(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestIndexModel{}, "indexed", &ModelConfig{SyncIndexes: true}); err != nil {
+		t.Fatal("DB: indexes were not synced on register", err)
+	}
+
+	model := db.Model("TestIndexModel")
+	ctx := context.Background()
+
+	indexes, _ := model.collection.Indexes(ctx)
+
+	if len(indexes) != 6 {
+		t.Fatalf("DB: expected id index and 5 declared indexes, got %v", indexes)
+	}
+
+	//an unknown index is reported, a changed index is conflicting
+	model.CreateIndex(Index{Key: []string{"legacy"}})
+	model.collection.DropIndex(ctx, "email_1")
+	model.CreateIndex(Index{Key: []string{"email"}})
+
+	report, err := model.SyncIndexes()
+
+	if err != nil {
+		t.Fatal("DB: indexes could not be synced", err)
+	}
+
+	if len(report.Created) != 0 || len(report.Dropped) != 0 || !reflect.DeepEqual(report.Obsolete, []string{"legacy_1"}) || !reflect.DeepEqual(report.Conflicting, []string{"email_1"}) {
+		t.Errorf("DB: unexpected sync report %+v", report)
+	}
+
+	model.config.DropIndexes = true
+
+	if report, err = model.SyncIndexes(); err != nil {
+		t.Fatal("DB: indexes could not be synced", err)
+	}
+
+	if !reflect.DeepEqual(report.Created, []string{"email_1"}) || !reflect.DeepEqual(report.Dropped, []string{"email_1", "legacy_1"}) {
+		t.Errorf("DB: unexpected sync report %+v", report)
+	}
+
+	indexes, _ = model.collection.Indexes(ctx)
+
+	for _, index := range indexes {
+
+		if index.Name == "email_1" && !index.Unique {
+			t.Error("DB: changed index was not recreated")
+		}
+	}
+
+	if report, _ = model.SyncIndexes(); len(report.Created)+len(report.Dropped)+len(report.Obsolete)+len(report.Conflicting) > 0 {
+		t.Errorf("DB: synced indexes must not change, got %+v", report)
+	}
+}
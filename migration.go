@@ -0,0 +1,535 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//migrationLockId is the id of the lock document in the migrations collection
+const migrationLockId = "lock"
+
+/*
+Migration is a versioned change of the stored documents, e.g. a renamed key or a new required field.
+Up applies the change, Down reverts it. A migration without Down can not be rolled back.
+Both functions get the connection of the migrator, so all models and queries can be used.
+*/
+type Migration struct {
+	Version int64  // unique version, migrations are applied in ascending order (e.g. 20180124153000)
+	Name    string // short description for the status
+	Up      func(ctx context.Context, connection *Connection) error
+	Down    func(ctx context.Context, connection *Connection) error
+}
+
+//MigrationStatus describes a migration which is registered or was applied before (see: func (*Migrator) Status)
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // applied but not registered (e.g. removed from the code)
+}
+
+/*
+MigrationError is returned if a migration failed. All migrations before it were applied (or reverted),
+the failed migration itself is not recorded.
+*/
+type MigrationError struct {
+	*QueryError
+	Version int64
+	Err     error // error of the up or down function
+}
+
+func (self *MigrationError) Unwrap() error {
+	return self.Err
+}
+
+//MigrationLockError is returned if another instance is migrating (see: MigratorConfig.LockTimeout)
+type MigrationLockError struct {
+	*QueryError
+	Owner    string
+	LockedAt time.Time
+}
+
+//MigratorConfig contains the settings of a migrator (see: NewMigrator)
+type MigratorConfig struct {
+	Collection  string        // collection of the applied versions and the lock, default is "migrations"
+	LockTimeout time.Duration // a lock which is older is taken over (e.g. after a crash), default is 15 minutes
+}
+
+//Migrator applies and reverts migrations and records the applied versions in a collection.
+type Migrator struct {
+	connection  *Connection
+	collection  string
+	lockTimeout time.Duration
+	migrations  []*Migration // sorted by version
+}
+
+//migrationRecord is stored for each applied migration
+type migrationRecord struct {
+	Version   int64     `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+/*
+NewMigrator returns a migrator for the database of the connection. Pass a tenant view to migrate the database of a tenant
+(see: func (*Connection) Tenant). Only one instance can migrate a database at the same time, the lock is stored in the
+migrations collection.
+
+For example:
+	migrator := mongodm.NewMigrator(connection, nil)
+
+	migrator.Register(mongodm.Migration{
+		Version: 1,
+		Name:    "rename firstname",
+		Up: func(ctx context.Context, connection *mongodm.Connection) error {
+
+			users := []*User{}
+
+			if err := connection.Model("User").Find(bson.M{"firstname": bson.M{"$exists": true}}).ExecContext(ctx, &users); err != nil {
+				return err
+			}
+
+			...
+		},
+	})
+
+	applied, err := migrator.Up(ctx)
+*/
+func NewMigrator(connection *Connection, config *MigratorConfig) *Migrator {
+
+	migrator := &Migrator{
+		connection:  connection,
+		collection:  "migrations",
+		lockTimeout: 15 * time.Minute,
+	}
+
+	if config != nil && len(config.Collection) > 0 {
+		migrator.collection = config.Collection
+	}
+
+	if config != nil && config.LockTimeout > 0 {
+		migrator.lockTimeout = config.LockTimeout
+	}
+
+	return migrator
+}
+
+//Register adds migrations, registering a version twice or a migration without Up returns an error.
+func (self *Migrator) Register(migrations ...Migration) error {
+
+	for index := range migrations {
+
+		migration := migrations[index]
+
+		if migration.Up == nil {
+			return fmt.Errorf("DB: Migration %v has no up function", migration.Version)
+		}
+
+		for _, existing := range self.migrations {
+
+			if existing.Version == migration.Version {
+				return fmt.Errorf("DB: Migration %v is already registered (%v)", migration.Version, existing.Name)
+			}
+		}
+
+		self.migrations = append(self.migrations, &migration)
+	}
+
+	sort.Slice(self.migrations, func(i, j int) bool {
+		return self.migrations[i].Version < self.migrations[j].Version
+	})
+
+	return nil
+}
+
+//Status returns all registered and all applied migrations in ascending order.
+func (self *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+
+	records, err := self.records(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statusByVersion := map[int64]*MigrationStatus{}
+
+	for _, migration := range self.migrations {
+		statusByVersion[migration.Version] = &MigrationStatus{Version: migration.Version, Name: migration.Name}
+	}
+
+	for _, record := range records {
+
+		status, ok := statusByVersion[record.Version]
+
+		if !ok {
+			status = &MigrationStatus{Version: record.Version, Name: record.Name, Missing: true}
+			statusByVersion[record.Version] = status
+		}
+
+		status.Applied = true
+		status.AppliedAt = record.AppliedAt
+	}
+
+	result := make([]MigrationStatus, 0, len(statusByVersion))
+
+	for _, status := range statusByVersion {
+		result = append(result, *status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+//Up applies all pending migrations and returns their versions.
+func (self *Migrator) Up(ctx context.Context) ([]int64, error) {
+
+	return self.UpTo(ctx, 0)
+}
+
+//UpTo applies all pending migrations up to the given version (0 applies all) and returns their versions.
+func (self *Migrator) UpTo(ctx context.Context, version int64) ([]int64, error) {
+
+	applied := []int64{}
+
+	err := self.locked(ctx, "Migrate up", func(ctx context.Context, lock *migrationLock) error {
+
+		records, err := self.records(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		done := make(map[int64]bool, len(records))
+
+		for _, record := range records {
+			done[record.Version] = true
+		}
+
+		for _, migration := range self.migrations {
+
+			if done[migration.Version] || (version > 0 && migration.Version > version) {
+				continue
+			}
+
+			if err := migration.Up(ctx, self.connection); err != nil {
+				return &MigrationError{&QueryError{fmt.Sprintf("DB: Migration %v (%v) failed: %v", migration.Version, migration.Name, err)}, migration.Version, err}
+			}
+
+			//a migrator which lost its lock must not record anything
+			if err := lock.renew(ctx); err != nil {
+				return err
+			}
+
+			if err := lock.collection.Insert(ctx, &migrationRecord{migration.Version, migration.Name, time.Now()}); err != nil {
+				return err
+			}
+
+			applied = append(applied, migration.Version)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+//Down reverts the given number of applied migrations, starting with the latest one, and returns their versions.
+func (self *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+
+	reverted := []int64{}
+
+	err := self.locked(ctx, "Migrate down", func(ctx context.Context, lock *migrationLock) error {
+
+		records, err := self.records(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		migrations := make(map[int64]*Migration, len(self.migrations))
+
+		for _, migration := range self.migrations {
+			migrations[migration.Version] = migration
+		}
+
+		for index := len(records) - 1; index >= 0 && len(reverted) < steps; index-- {
+
+			record := records[index]
+			migration, ok := migrations[record.Version]
+
+			if !ok {
+				return &MigrationError{&QueryError{fmt.Sprintf("DB: Migration %v (%v) is not registered", record.Version, record.Name)}, record.Version, nil}
+			}
+
+			if migration.Down == nil {
+				return &MigrationError{&QueryError{fmt.Sprintf("DB: Migration %v (%v) can not be reverted", migration.Version, migration.Name)}, migration.Version, nil}
+			}
+
+			if err := migration.Down(ctx, self.connection); err != nil {
+				return &MigrationError{&QueryError{fmt.Sprintf("DB: Migration %v (%v) could not be reverted: %v", migration.Version, migration.Name, err)}, migration.Version, err}
+			}
+
+			if err := lock.renew(ctx); err != nil {
+				return err
+			}
+
+			if _, err := lock.collection.Remove(ctx, bson.M{"_id": record.Version}); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, record.Version)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+/*
+Command runs the migrator as command line tool, so a project can add a migration binary with its registered migrations.
+The commands are "status", "up [version]" and "down [steps]" (default is one step).
+
+For example (cmd/migrate/main.go of your project):
+
+	func main() {
+
+		connection, err := mongodm.Connect(dbConfig)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer connection.Close()
+
+		migrator := mongodm.NewMigrator(connection, nil)
+		migrator.Register(migrations.All...)
+
+		if err := migrator.Command(context.Background(), os.Args[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+*/
+func (self *Migrator) Command(ctx context.Context, args []string, output io.Writer) error {
+
+	if output == nil {
+		output = os.Stdout
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("DB: Missing command, usage: status | up [version] | down [steps]")
+	}
+
+	argument := func(defaultValue int64) (int64, error) {
+
+		if len(args) < 2 {
+			return defaultValue, nil
+		}
+
+		return strconv.ParseInt(args[1], 10, 64)
+	}
+
+	switch args[0] {
+	case "status":
+
+		statuses, err := self.Status(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+
+			state := "pending"
+
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+
+			if status.Missing {
+				state += " (missing)"
+			}
+
+			fmt.Fprintf(output, "%v\t%v\t%v\n", status.Version, status.Name, state)
+		}
+
+		return nil
+
+	case "up":
+
+		version, err := argument(0)
+
+		if err != nil {
+			return fmt.Errorf("DB: Invalid version '%v'", args[1])
+		}
+
+		applied, err := self.UpTo(ctx, version)
+
+		for _, version := range applied {
+			fmt.Fprintf(output, "applied %v\n", version)
+		}
+
+		return err
+
+	case "down":
+
+		steps, err := argument(1)
+
+		if err != nil || steps < 1 {
+			return fmt.Errorf("DB: Invalid number of steps '%v'", args[1])
+		}
+
+		reverted, err := self.Down(ctx, int(steps))
+
+		for _, version := range reverted {
+			fmt.Fprintf(output, "reverted %v\n", version)
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("DB: Unknown command '%v', usage: status | up [version] | down [steps]", args[0])
+}
+
+//backendCollection returns the migrations collection in the database of the connection
+func (self *Migrator) backendCollection() BackendCollection {
+
+	collection := self.connection.backend.Collection(self.connection.Database(), self.collection, nil)
+
+	return &connectionCollection{collection, self.connection}
+}
+
+//records returns all applied migrations in ascending order
+func (self *Migrator) records(ctx context.Context) ([]migrationRecord, error) {
+
+	raws, err := self.backendCollection().Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockId}}, &FindOptions{Sort: []string{"_id"}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]migrationRecord, len(raws))
+
+	for index, raw := range raws {
+
+		if err := raw.Unmarshal(&records[index]); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+/*
+locked runs fn while the migration lock is held. The lock is a document with a fixed id, so only one instance can insert it.
+A lock which is older than the lock timeout is removed first, the remove only matches the stale lock, so a fresh lock of
+another instance is never taken over. While fn runs, the lock is renewed regularly, so long migrations keep it.
+*/
+func (self *Migrator) locked(ctx context.Context, description string, fn func(ctx context.Context, lock *migrationLock) error) error {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("%v on collection '%v'", description, self.collection))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	collection := self.backendCollection()
+
+	if _, err := collection.Remove(ctx, bson.M{"_id": migrationLockId, "lockedAt": bson.M{"$lt": time.Now().Add(-self.lockTimeout)}}); err != nil {
+		return err
+	}
+
+	//the random part distinguishes migrators of the same process
+	hostname, _ := os.Hostname()
+	lock := &migrationLock{collection, fmt.Sprintf("%v:%v:%v", hostname, os.Getpid(), bson.NewObjectId().Hex())}
+
+	err = collection.Insert(ctx, bson.M{"_id": migrationLockId, "owner": lock.owner, "lockedAt": time.Now()})
+
+	if _, duplicate := err.(*DuplicateError); duplicate {
+		return lock.lockError(ctx, "DB: Migrations are locked by '%v' since %v")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer collection.Remove(context.Background(), bson.M{"_id": migrationLockId, "owner": lock.owner})
+
+	renewal := make(chan struct{})
+
+	defer close(renewal)
+
+	go func() {
+
+		ticker := time.NewTicker(self.lockTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-renewal:
+				return
+			case <-ticker.C:
+				lock.renew(ctx)
+			}
+		}
+	}()
+
+	return fn(ctx, lock)
+}
+
+//migrationLock is the lock document of a running migrator
+type migrationLock struct {
+	collection BackendCollection
+	owner      string
+}
+
+/*
+renew refreshes the lock time, a *MigrationLockError is returned if the lock was taken over in the meantime. Only an
+owned lock is removed and inserted again, if another instance inserts its lock in between, the insert fails.
+*/
+func (self *migrationLock) renew(ctx context.Context) error {
+
+	removed, err := self.collection.Remove(ctx, bson.M{"_id": migrationLockId, "owner": self.owner})
+
+	if err != nil {
+		return err
+	} else if removed == 0 {
+		return self.lockError(ctx, "DB: The migration lock was taken over by '%v' at %v")
+	}
+
+	err = self.collection.Insert(ctx, bson.M{"_id": migrationLockId, "owner": self.owner, "lockedAt": time.Now()})
+
+	if _, duplicate := err.(*DuplicateError); duplicate {
+		return self.lockError(ctx, "DB: The migration lock was taken over by '%v' at %v")
+	}
+
+	return err
+}
+
+//lockError returns a *MigrationLockError with the current lock, the message gets its owner and time
+func (self *migrationLock) lockError(ctx context.Context, message string) error {
+
+	lock := struct {
+		Owner    string    `bson:"owner"`
+		LockedAt time.Time `bson:"lockedAt"`
+	}{}
+
+	if raws, err := self.collection.Find(ctx, bson.M{"_id": migrationLockId}, nil); err == nil && len(raws) > 0 {
+		raws[0].Unmarshal(&lock)
+	}
+
+	return &MigrationLockError{&QueryError{fmt.Sprintf(message, lock.Owner, lock.LockedAt.Format(time.RFC3339))}, lock.Owner, lock.LockedAt}
+}
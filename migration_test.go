@@ -0,0 +1,210 @@
+package mongodm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMigrations(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	steps := []string{}
+
+	step := func(name string) func(ctx context.Context, connection *Connection) error {
+		return func(ctx context.Context, connection *Connection) error {
+			steps = append(steps, name)
+			return nil
+		}
+	}
+
+	migrator := NewMigrator(db, nil)
+
+	err := migrator.Register(
+		Migration{Version: 3, Name: "third", Up: step("up 3")},
+		Migration{Version: 1, Name: "first", Up: step("up 1"), Down: step("down 1")},
+		Migration{Version: 2, Name: "second", Up: func(ctx context.Context, connection *Connection) error {
+
+			//migrations can use the models of the connection
+			testModel := &TestModel{}
+
+			connection.Model("TestModel").New(testModel)
+
+			testModel.Name = "Migrated"
+			testModel.RequiredField = "Test"
+			testModel.Relation1N = []bson.ObjectId{}
+
+			steps = append(steps, "up 2")
+
+			return testModel.SaveContext(ctx)
+
+		}, Down: step("down 2")},
+	)
+
+	if err != nil {
+		t.Fatal("DB: migrations could not be registered", err)
+	}
+
+	if err := migrator.Register(Migration{Version: 1, Up: step("again")}); err == nil {
+		t.Error("DB: expected error for a duplicated version")
+	}
+
+	if applied, err := migrator.UpTo(ctx, 2); err != nil || !reflect.DeepEqual(applied, []int64{1, 2}) {
+		t.Fatal("DB: migrations were not applied", applied, err)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || !reflect.DeepEqual(applied, []int64{3}) {
+		t.Fatal("DB: pending migration was not applied", applied, err)
+	}
+
+	if count, _ := db.Model("TestModel").Find(bson.M{"name": "Migrated"}).Count(); count != 1 {
+		t.Error("DB: migration did not store the document", count)
+	}
+
+	//the third migration can not be reverted
+	if reverted, err := migrator.Down(ctx, 1); len(reverted) != 0 || err == nil {
+		t.Error("DB: expected error for an irreversible migration", reverted, err)
+	}
+
+	migrator.migrations[2].Down = step("down 3")
+
+	if reverted, err := migrator.Down(ctx, 2); err != nil || !reflect.DeepEqual(reverted, []int64{3, 2}) {
+		t.Fatal("DB: migrations were not reverted", reverted, err)
+	}
+
+	if !reflect.DeepEqual(steps, []string{"up 1", "up 2", "up 3", "down 3", "down 2"}) {
+		t.Error("DB: unexpected migration order", steps)
+	}
+
+	statuses, err := migrator.Status(ctx)
+
+	if err != nil || len(statuses) != 3 || !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied {
+		t.Error("DB: unexpected migration status", statuses, err)
+	}
+
+	//a failed migration is not recorded
+	failing := errors.New("failed")
+
+	migrator.Register(Migration{Version: 4, Name: "failing", Up: func(ctx context.Context, connection *Connection) error {
+		return failing
+	}})
+
+	var migrationError *MigrationError
+
+	if applied, err := migrator.Up(ctx); !errors.As(err, &migrationError) || migrationError.Version != 4 || !errors.Is(err, failing) || !reflect.DeepEqual(applied, []int64{2, 3}) {
+		t.Error("DB: expected migration error", applied, err)
+	}
+
+	if statuses, _ := migrator.Status(ctx); statuses[3].Applied {
+		t.Error("DB: failed migration was recorded")
+	}
+}
+
+func TestMigrationLockRenewal(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	config := &MigratorConfig{LockTimeout: 60 * time.Millisecond}
+	migrator := NewMigrator(db, config)
+
+	migrator.Register(Migration{Version: 1, Name: "slow", Up: func(ctx context.Context, connection *Connection) error {
+
+		time.Sleep(200 * time.Millisecond)
+
+		//the lock of a long migration does not get stale
+		if _, err := NewMigrator(connection, config).Up(ctx); err == nil {
+			t.Error("DB: expected lock error")
+		} else if _, ok := err.(*MigrationLockError); !ok {
+			t.Error("DB: expected lock error", err)
+		}
+
+		return nil
+
+	}}, Migration{Version: 2, Name: "taken over", Up: func(ctx context.Context, connection *Connection) error {
+
+		//another instance takes over the lock
+		collection := migrator.backendCollection()
+
+		collection.Remove(ctx, bson.M{"_id": migrationLockId})
+		collection.Insert(ctx, bson.M{"_id": migrationLockId, "owner": "other", "lockedAt": time.Now()})
+
+		return nil
+	}})
+
+	applied, err := migrator.Up(ctx)
+
+	if lockError, ok := err.(*MigrationLockError); !ok || lockError.Owner != "other" || !reflect.DeepEqual(applied, []int64{1}) {
+		t.Error("DB: expected lock error for the lost lock", applied, err)
+	}
+
+	if statuses, _ := migrator.Status(ctx); !statuses[0].Applied || statuses[1].Applied {
+		t.Error("DB: migration was recorded without lock", statuses)
+	}
+}
+
+func TestMigrationLock(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := NewMigrator(db, &MigratorConfig{LockTimeout: time.Minute})
+
+	migrator.Register(Migration{Version: 1, Name: "first", Up: func(ctx context.Context, connection *Connection) error {
+
+		//another instance can not migrate meanwhile
+		if _, err := NewMigrator(connection, nil).Up(ctx); err == nil {
+			t.Error("DB: expected lock error")
+		} else if _, ok := err.(*MigrationLockError); !ok {
+			t.Error("DB: expected lock error", err)
+		}
+
+		return nil
+
+	}, Down: func(ctx context.Context, connection *Connection) error {
+		return nil
+	}})
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal("DB: migrations were not applied", err)
+	}
+
+	//the lock was released
+	if count, _ := migrator.backendCollection().Count(ctx, bson.M{"_id": migrationLockId}); count != 0 {
+		t.Error("DB: lock was not released")
+	}
+
+	//a stale lock of a crashed instance is taken over
+	migrator.backendCollection().Insert(ctx, bson.M{"_id": migrationLockId, "owner": "crashed", "lockedAt": time.Now().Add(-time.Hour)})
+
+	if _, err := migrator.Status(ctx); err != nil {
+		t.Error("DB: status must ignore the lock", err)
+	}
+
+	var output bytes.Buffer
+
+	if err := migrator.Command(ctx, []string{"down"}, &output); err != nil || output.String() != "reverted 1\n" {
+		t.Error("DB: stale lock was not taken over", output.String(), err)
+	}
+
+	output.Reset()
+
+	if err := migrator.Command(ctx, []string{"status"}, &output); err != nil || !strings.HasPrefix(output.String(), "1\tfirst\tpending") {
+		t.Error("DB: unexpected status output", output.String(), err)
+	}
+
+	if err := migrator.Command(ctx, []string{"sideways"}, &output); err == nil {
+		t.Error("DB: expected error for an unknown command")
+	}
+}
@@ -1,6 +1,9 @@
 package mongodm
 
 import (
+	"context"
+	"fmt"
+
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -8,12 +11,44 @@ import (
 /*
 The Model type stores a databse connection and single collection for a specific type (e.g. "users").
 New model types can be registered with the help of the connection (see func (*Connection) Register).
-Also an instance of this type embeds the default *mgo.Collection functionallity so you can call all native
-mgo collection API`s, too.
+When the connection uses the mgo driver, an instance of this type also embeds the default *mgo.Collection functionallity
+so you can call all native mgo collection API`s, too. For all other drivers the embedded collection is nil.
 */
 type Model struct {
 	*mgo.Collection
 	connection *Connection
+	collection BackendCollection
+	database   string // empty for the default database of the connection
+	name       string
+	config     ModelConfig
+	schema     *schema // parsed tags of the document type
+}
+
+/*
+ModelConfig contains per model settings which can be passed on registration (see: func (*Connection) Register).
+
+For example:
+	connection.Register(&Report{}, "reports", &mongodm.ModelConfig{Mode: mongodm.SecondaryPreferred})
+*/
+type ModelConfig struct {
+	Name    string   // model name for connection.Model() and relation tags, default is the type name
+	Aliases []string // additional names of the model
+
+	Mode Mode  // consistency mode for all operations of the model, default is the mode of the connection
+	Safe *Safe // write concern for all writes of the model, default is the write concern of the connection
+
+	SyncIndexes bool // create the indexes declared with index tags on registration
+	DropIndexes bool // let SyncIndexes drop indexes which are not declared or differ from their declaration
+
+	Validator *ValidatorConfig // apply the $jsonSchema of the document type as collection validator on registration
+
+	Projection ProjectionMode // how Save handles documents which were loaded with Query.Select, default is ProjectionUpdate
+
+	KeepExtras bool // keep stored keys without struct field in DocumentBase.Extras and write them back on save
+
+	Versioning bool // increment DocumentBase.Version on each save and reject saves of stale documents with a *ConflictError
+
+	Upgrades []Upgrade // upgrades of stored documents, Upgrades[n] converts version n to n+1 (see: func (*Model) SchemaVersion)
 }
 
 /*
@@ -40,10 +75,15 @@ func (self *Model) New(document IDocumentBase, content ...interface{}) (error, m
 
 	//init collection and set pointer to its own collection (this is needed for odm operations)
 
-	document.SetCollection(self.Collection)
+	document.SetModel(self)
 	document.SetDocument(document)
 	document.SetConnection(self.connection)
 
+	//changes are tracked from now on (see: func (*DocumentBase) IsDirty)
+	if tracked, ok := document.(documentBase); ok {
+		tracked.base().track()
+	}
+
 	if len(content) > 0 {
 		return document.Update(content[0])
 	}
@@ -73,7 +113,7 @@ For example:
 func (self *Model) FindId(id bson.ObjectId) *Query {
 
 	return &Query{
-		collection: self.Collection,
+		model:      self,
 		connection: self.connection,
 		query:      bson.M{"_id": id},
 		multiple:   false,
@@ -90,7 +130,7 @@ For example:
 
 	user := &models.User{}
 
-	err := User.FindOne(bson.M{"firstname" : "Max", "deleted" : false}).Populate("Messages").Exec(user)
+	err := User.FindOne(bson.M{"firstname" : "Max"}).Populate("Messages").Exec(user)
 
 	if _, ok := err.(*mongodm.NotFoundError); ok {
 		//no records were found
@@ -114,7 +154,7 @@ func (self *Model) FindOne(query ...interface{}) *Query {
 	}
 
 	return &Query{
-		collection: self.Collection,
+		model:      self,
 		connection: self.connection,
 		query:      finalQuery,
 		multiple:   false,
@@ -130,7 +170,7 @@ For example:
 
 	users := []*models.User{}
 
-	err := User.Find(bson.M{"firstname" : "Max", "deleted" : false}).Populate("Messages").Exec(&users)
+	err := User.Find(bson.M{"firstname" : "Max"}).Populate("Messages").Exec(&users)
 
 	if _, ok := err.(*mongodm.NotFoundError); ok { //you also can check the length of the slice
 		//no records were found
@@ -157,8 +197,60 @@ func (self *Model) Find(query ...interface{}) *Query {
 
 	return &Query{
 		query:      finalQuery,
-		collection: self.Collection,
+		model:      self,
 		connection: self.connection,
 		multiple:   true,
 	}
 }
+
+/*
+CreateIndex ensures that an index exists for the collection of the model. It works with every driver, whereas the
+EnsureIndex method of the embedded *mgo.Collection is only available for the mgo driver.
+
+For example:
+	User := connection.Model("User")
+
+	err := User.CreateIndex(mongodm.Index{Key: []string{"email"}, Unique: true})
+*/
+func (self *Model) CreateIndex(index Index) error {
+
+	return self.CreateIndexContext(context.Background(), index)
+}
+
+//CreateIndexContext works like CreateIndex but is canceled as soon as the given context is done.
+func (self *Model) CreateIndexContext(ctx context.Context, index Index) error {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("CreateIndex on collection '%v'", self.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	return self.collection.EnsureIndex(ctx, index)
+}
+
+//backendCollection returns a collection handle with the model settings, a mode other than DefaultMode overrides the mode of the model
+func (self *Model) backendCollection(mode Mode) BackendCollection {
+
+	if mode == DefaultMode {
+
+		if self.collection != nil {
+			return self.collection
+		}
+
+		mode = self.config.Mode
+	}
+
+	var options *SessionOptions
+
+	if mode != DefaultMode || self.config.Safe != nil {
+		options = &SessionOptions{Mode: mode, Safe: self.config.Safe}
+	}
+
+	// empty string returns db name from dial info
+	collection := self.connection.backend.Collection(self.database, self.name, options)
+
+	return &connectionCollection{collection, self.connection}
+}
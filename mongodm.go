@@ -1,5 +1,6 @@
 /*
-This package is an object document mapper for mongodb which uses the mgo adapter.
+This package is an object document mapper for mongodb which uses the mgo adapter by default. The official
+go.mongodb.org/mongo-driver can be selected with the Driver option of the connection config.
 
 First step is to create a model, for example:
 
@@ -76,6 +77,7 @@ Now that you got some models it is important to create a connection to the datab
 package mongodm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -84,6 +86,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	mgo "gopkg.in/mgo.v2"
@@ -95,9 +98,12 @@ const REL_1N string = "1n" // one-to-many relation
 
 var locals map[string]string
 
+var documentBaseType = reflect.TypeOf((*IDocumentBase)(nil)).Elem()
+
 type (
 	//Simple config object which has to be passed/set to create a new connection
 	Config struct {
+		URI              string // standard connection string, takes precedence over all Database* attributes (see: ParseURI)
 		DatabaseHosts    []string
 		DatabaseName     string
 		DatabaseUser     string
@@ -105,14 +111,37 @@ type (
 		DatabaseSource   string
 		DialInfo         *mgo.DialInfo
 		Locals           map[string]string
+		Driver           Driver // database adapter, default is DriverMgo
+
+		//session settings, explicitly set values take precedence over the options of the URI or DialInfo
+		Mode          Mode          // consistency mode of the session, default is Monotonic
+		Safe          *Safe         // write concern, default is the one of the driver (acknowledged writes)
+		DialTimeout   time.Duration // timeout for establishing the connection, default is 3 seconds
+		SocketTimeout time.Duration // timeout of a single socket operation, default is the one of the driver
+		SyncTimeout   time.Duration // time to wait for a suitable server, default is the dial timeout
+		PoolLimit     int           // maximum number of sockets per server, default is the one of the driver
+
+		Strict bool // panic on schema and relation errors instead of returning them (useful during development)
+
+		Reconnect     *ReconnectConfig                         // reconnect automatically after network errors, default is no reconnection
+		OnStateChange func(state ConnectionState, err error) // called when the connection gets connected, disconnected, reconnected or closed
 	}
 
 	//The "Database" object which stores all connections
 	Connection struct {
 		Config        *Config
-		Session       *mgo.Session
+		Session       *mgo.Session // only set when the connection uses DriverMgo
+		backend       Backend
+		registryMutex sync.RWMutex
 		modelRegistry map[string]*Model
 		typeRegistry  map[string]reflect.Type
+		tenant        *tenantView // only set for tenant views (see: func (*Connection) Tenant)
+		tenants       map[string]*Connection
+		stateMutex    sync.Mutex
+		state         ConnectionState
+		reconnecting  bool
+		closing       chan struct{} // closed as soon as the connection gets closed
+		operations    operationTracker
 	}
 
 	//Interface which each collection document (model) hast to implement
@@ -129,11 +158,12 @@ type (
 		SetDeleted(bool)
 		IsDeleted() bool
 
-		SetCollection(*mgo.Collection)
+		SetModel(*Model)
 		SetDocument(document IDocumentBase)
 		SetConnection(*Connection)
 
 		Save() error
+		SaveContext(context.Context) error
 		Update(interface{}) (error, map[string]interface{})
 		Validate(...interface{}) (bool, []error)
 		DefaultValidate() (bool, []error)
@@ -193,21 +223,14 @@ func Connect(config *Config) (*Connection, error) {
 	return con, err
 }
 
-func (self *Connection) document(typeName string) IDocumentBase {
-
-	typeNameLC := strings.ToLower(typeName)
+//fail panics with the error in strict mode (see: Config.Strict), otherwise the error is returned
+func (self *Connection) fail(err error) error {
 
-	if _, ok := self.typeRegistry[typeNameLC]; ok {
-
-		reflectType := self.typeRegistry[typeNameLC]
-		document := reflect.New(reflectType).Interface().(IDocumentBase)
-
-		self.modelRegistry[typeNameLC].New(document)
-
-		return document
+	if self.Config != nil && self.Config.Strict {
+		panic(err)
 	}
 
-	panic(fmt.Sprintf("DB: Type '%v' is not registered", typeName))
+	return err
 }
 
 func L(key string, values ...interface{}) string {
@@ -226,6 +249,7 @@ func L(key string, values ...interface{}) string {
 /*
 To create actions on each collection you have to request a model instance with this method.
 Make sure that you registered your collections and schemes first, otherwise it will panic.
+Use LookupModel if the model may not be registered yet.
 
 For example:
 	User := connection.Model("User")
@@ -234,49 +258,241 @@ For example:
 */
 func (self *Connection) Model(typeName string) *Model {
 
-	typeNameLC := strings.ToLower(typeName)
+	model, err := self.LookupModel(typeName)
+
+	if err != nil {
+		panic(err.Error())
+	}
 
-	if _, ok := self.modelRegistry[typeNameLC]; ok {
+	return model
+}
+
+/*
+LookupModel works like Model but returns a *RegistryError instead of panicking if the type is not registered.
 
-		return self.modelRegistry[typeNameLC]
+For example:
+	User, err := connection.LookupModel("User")
+
+	if _, ok := err.(*mongodm.RegistryError); ok {
+		//model was not registered yet
 	}
+*/
+func (self *Connection) LookupModel(typeName string) (*Model, error) {
+
+	if self.tenant != nil {
 
-	panic(fmt.Sprintf("DB: Type '%v' is not registered", typeName))
+		model, err := self.tenant.root.LookupModel(typeName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return self.tenantModel(model), nil
+	}
+
+	self.registryMutex.RLock()
+	defer self.registryMutex.RUnlock()
+
+	if model, ok := self.modelRegistry[strings.ToLower(typeName)]; ok {
+		return model, nil
+	}
+
+	return nil, &RegistryError{&QueryError{fmt.Sprintf("DB: Type '%v' is not registered", typeName)}}
 }
 
 /*
 It is necessary to register your created models to the ODM to work with. Within this process
 the ODM creates an internal model and type registry to work fully automatically and consistent.
 Make sure you already created a connection. Registration expects a pointer to an IDocumentBase
-type and the collection name where the documents should be stored in.
+type and the collection name where the documents should be stored in. Optionally a model config can be passed
+to override the session mode and write concern of the connection or the name of the model for this model.
 
 For example:
 	connection.Register(&User{}, "users")
 	connection.Register(&Message{}, "messages")
 	connection.Register(&Customer{}, "customers")
+	connection.Register(&Report{}, "reports", &mongodm.ModelConfig{Mode: mongodm.SecondaryPreferred})
+
+Each model is registered with its type name (e.g. "User") and its package-qualified type name
+(e.g. "github.com/acme/billing.User"). If two packages define a type with the same name, pass an explicit
+name or aliases for at least one of them:
+
+	connection.Register(&billing.User{}, "billingUsers", &mongodm.ModelConfig{Name: "BillingUser", Aliases: []string{"billing.User"}})
+
+Registration is safe for concurrent use. Registering the same type for the same collection again has no effect,
+registering another type with an already used name or the same type for another collection returns a *RegistryError.
+Invalid tags (e.g. minLen:"two") are reported as *SchemaError. If ModelConfig.SyncIndexes is set, the indexes declared
+with index tags are created on the first registration (see: func (*Model) SyncIndexes), ModelConfig.Validator
+applies the collection validator (see: func (*Model) ApplyValidator).
 */
-func (self *Connection) Register(document IDocumentBase, collectionName string) {
+func (self *Connection) Register(document IDocumentBase, collectionName string, config ...*ModelConfig) error {
 
 	if document == nil {
 		panic("document can not be nil")
 	}
 
-	reflectType := reflect.TypeOf(document)
-	typeName := strings.ToLower(reflectType.Elem().Name())
+	//tenant views share the registry of their connection
+	if self.tenant != nil {
+		return self.tenant.root.Register(document, collectionName, config...)
+	}
 
-	//check if model was already registered
-	if _, ok := self.modelRegistry[typeName]; !ok {
-		collection := self.Session.DB("").C(collectionName) // empty string returns db name from dial info
-		model := &Model{collection, self}
+	if len(config) > 1 {
+		panic("DB: Register method accepts no or maximum one model config.")
+	}
+
+	reflectType := reflect.TypeOf(document).Elem()
+	qualifiedName := strings.ToLower(qualifiedTypeName(reflectType))
+
+	//parse all tags once, so tag errors are reported here instead of on the first save
+	documentSchema, err := schemaOf(reflectType)
+
+	if err != nil {
+		return err
+	}
 
-		self.modelRegistry[typeName] = model
-		self.typeRegistry[typeName] = reflectType.Elem()
+	modelConfig := ModelConfig{}
+
+	if len(config) == 1 && config[0] != nil {
+		modelConfig = *config[0]
+	}
 
-		fmt.Sprintf("Registered type '%v' for collection '%v'", typeName, collectionName)
+	names := []string{qualifiedName}
 
+	if len(modelConfig.Name) > 0 {
+		names = append(names, strings.ToLower(modelConfig.Name))
 	} else {
-		fmt.Sprintf("Tried to register type '%v' twice", typeName)
+		names = append(names, strings.ToLower(reflectType.Name()))
+	}
+
+	for _, alias := range modelConfig.Aliases {
+		names = append(names, strings.ToLower(alias))
+	}
+
+	model, created, err := self.registerModel(reflectType, qualifiedName, names, collectionName, modelConfig, documentSchema)
+
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		return nil
+	}
+
+	//the collection is prepared outside of the registry lock, so other models can be used meanwhile
+	if modelConfig.Validator != nil {
+
+		if err := model.ApplyValidator(modelConfig.Validator); err != nil {
+			return err
+		}
 	}
+
+	if modelConfig.SyncIndexes {
+		_, err = model.SyncIndexes()
+	}
+
+	return err
+}
+
+//registerModel adds the model to the registry, created reports if the model was not registered before
+func (self *Connection) registerModel(reflectType reflect.Type, qualifiedName string, names []string, collectionName string, modelConfig ModelConfig, documentSchema *schema) (*Model, bool, error) {
+
+	self.registryMutex.Lock()
+	defer self.registryMutex.Unlock()
+
+	//check if model was already registered
+	if existing, ok := self.modelRegistry[qualifiedName]; ok && existing.name != collectionName {
+		return nil, false, &RegistryError{&QueryError{fmt.Sprintf("DB: Type '%v' is already registered for collection '%v'", qualifiedName, existing.name)}}
+	}
+
+	//check all names first, so a conflict does not leave a partial registration behind
+	for _, name := range names {
+
+		if registeredType, ok := self.typeRegistry[name]; ok && registeredType != reflectType {
+			return nil, false, &RegistryError{&QueryError{fmt.Sprintf("DB: Model name '%v' is already registered for type '%v'", name, qualifiedTypeName(registeredType))}}
+		}
+	}
+
+	model, ok := self.modelRegistry[qualifiedName]
+
+	if !ok {
+
+		model = &Model{
+			connection: self,
+			name:       collectionName,
+			config:     modelConfig,
+			schema:     documentSchema,
+		}
+
+		model.collection = model.backendCollection(DefaultMode)
+
+		if self.Session != nil {
+			model.Collection = self.Session.DB("").C(collectionName)
+		}
+	}
+
+	for _, name := range names {
+		self.modelRegistry[name] = model
+		self.typeRegistry[name] = reflectType
+	}
+
+	return model, !ok, nil
+}
+
+//qualifiedTypeName returns the type name with its import path, e.g. "github.com/acme/billing.User"
+func qualifiedTypeName(reflectType reflect.Type) string {
+
+	if len(reflectType.PkgPath()) == 0 {
+		return reflectType.Name()
+	}
+
+	return reflectType.PkgPath() + "." + reflectType.Name()
+}
+
+/*
+relatedModel resolves the model tag of a relation field. The tag may contain a registered name, an alias or a
+package-qualified type name. An unqualified name is looked up in the package of the owner type first, so types of
+different packages with the same name can be related without aliases.
+*/
+func (self *Connection) relatedModel(owner reflect.Type, modelTag string) (*Model, IDocumentBase, error) {
+
+	if self.tenant != nil {
+
+		model, document, err := self.tenant.root.relatedModel(owner, modelTag)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		model = self.tenantModel(model)
+		model.New(document)
+
+		return model, document, nil
+	}
+
+	names := []string{modelTag}
+
+	if len(owner.PkgPath()) > 0 && !strings.Contains(modelTag, ".") {
+		names = []string{owner.PkgPath() + "." + modelTag, modelTag}
+	}
+
+	self.registryMutex.RLock()
+	defer self.registryMutex.RUnlock()
+
+	for _, name := range names {
+
+		nameLC := strings.ToLower(name)
+
+		if model, ok := self.modelRegistry[nameLC]; ok {
+
+			document := reflect.New(self.typeRegistry[nameLC]).Interface().(IDocumentBase)
+
+			model.New(document)
+
+			return model, document, nil
+		}
+	}
+
+	return nil, nil, &RegistryError{&QueryError{fmt.Sprintf("DB: Type '%v' is not registered", modelTag)}}
 }
 
 //Opens a database connection manually if the config was set.
@@ -296,37 +512,87 @@ func (self *Connection) Open() (err error) {
 		}
 	}()
 
-	var info *mgo.DialInfo
-	
-	if self.Config.DialInfo == info {
-		info = &mgo.DialInfo{
-			Addrs:    self.Config.DatabaseHosts,
-			Timeout:  3 * time.Second,
-			Database: self.Config.DatabaseName,
-			Username: self.Config.DatabaseUser,
-			Password: self.Config.DatabasePassword,
-			Source:   self.Config.DatabaseSource,
-		}
-	} else {
-		info = self.Config.DialInfo
+	if len(self.Config.URI) > 0 && self.Config.DialInfo != nil {
+		return errors.New("DB: The options URI and DialInfo can not be combined")
 	}
-	
-	session, err := mgo.DialWithInfo(info)
 
-	if err != nil {
-		return err
+	if self.Config.Mode < DefaultMode || self.Config.Mode > Nearest {
+		return fmt.Errorf("DB: Unknown mode '%v'", self.Config.Mode)
+	}
+
+	switch self.Config.Driver {
+
+	case DriverMgo, "":
+
+		backend, err := dialMgo(self.Config)
+
+		if err != nil {
+			return err
+		}
+
+		self.backend = backend
+		self.Session = backend.session
+
+	case DriverMongo:
+
+		backend, err := dialMongo(self.Config)
+
+		if err != nil {
+			return err
+		}
+
+		self.backend = backend
+
+	case DriverMemory:
+
+		backend, err := newMemoryBackend(self.Config)
+
+		if err != nil {
+			return err
+		}
+
+		self.backend = backend
+
+	default:
+		return fmt.Errorf("DB: Unknown driver '%v'", self.Config.Driver)
 	}
 
-	self.Session = session
+	self.stateMutex.Lock()
+	self.state = StateConnected
+	self.closing = make(chan struct{})
+	self.stateMutex.Unlock()
+
+	self.notify(StateConnected, nil)
 
-	self.Session.SetMode(mgo.Monotonic, true)
 	return nil
 }
 
-//Closes an existing database connection
+//Backend returns the storage backend of the connection.
+func (self *Connection) Backend() Backend {
+
+	return self.backend
+}
+
+//Closes an existing database connection. Closing a tenant view has no effect, close the connection it was created from instead.
 func (self *Connection) Close() {
 
-	if self.Session != nil {
-		self.Session.Close()
+	if self.tenant != nil || self.backend == nil {
+		return
+	}
+
+	self.stateMutex.Lock()
+
+	if self.state == StateClosed {
+		self.stateMutex.Unlock()
+		return
 	}
+
+	self.state = StateClosed
+	close(self.closing)
+
+	self.stateMutex.Unlock()
+
+	self.backend.Close()
+
+	self.notify(StateClosed, nil)
 }
@@ -1,6 +1,7 @@
 package mongodm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -144,6 +145,50 @@ func TestConnectionWithoutExtendedConfig(t *testing.T) {
 	}
 }
 
+func TestConnectionWithMongoDriver(t *testing.T) {
+
+	dbConfig := &Config{
+		DatabaseHosts:    []string{DBHost},
+		DatabaseName:     DBName,
+		DatabaseUser:     DBUser,
+		DatabasePassword: DBPass,
+		DatabaseSource:   DBSource,
+		Driver:           DriverMongo,
+	}
+
+	db, err := Connect(dbConfig)
+
+	if err != nil {
+
+		t.Error("DB: Connection error", err)
+		return
+	}
+
+	defer db.Close()
+
+	db.Register(&TestRelationModel{}, DBTestRelCollection)
+
+	TestRelation := db.Model("testrelationmodel")
+
+	testRelationModel := &TestRelationModel{}
+
+	TestRelation.New(testRelationModel)
+
+	testRelationModel.RelationName = "mongo driver relation"
+
+	if err := testRelationModel.Save(); err != nil {
+		t.Error("DB: creation error with mongo driver", err)
+	}
+
+	found := &TestRelationModel{}
+
+	if err := TestRelation.FindId(testRelationModel.Id).Exec(found); err != nil {
+		t.Error("DB: FindId failed with mongo driver", err)
+	} else if found.RelationName != testRelationModel.RelationName {
+		t.Error("DB: document was not stored correctly with mongo driver")
+	}
+}
+
 func TestCreate(t *testing.T) {
 
 	Test := dbConnection.Model("testmodel")
@@ -346,6 +391,37 @@ func TestSetNewRelationAndPopulate(t *testing.T) {
 	}
 }
 
+func TestContextCanceled(t *testing.T) {
+
+	Test := dbConnection.Model("testmodel")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testModelSlice := []*TestModel{}
+
+	err := Test.Find(bson.M{"deleted": false}).Populate("Relation11", "Relation1N").ExecContext(ctx, &testModelSlice)
+
+	if err != context.Canceled {
+		t.Error("DB: expected canceled context error for query execution", err)
+	}
+
+	if _, err := Test.Find().CountContext(ctx); err != context.Canceled {
+		t.Error("DB: expected canceled context error for count", err)
+	}
+
+	testModel := &TestModel{}
+
+	Test.New(testModel)
+
+	testModel.Name = "Canceled test"
+	testModel.RequiredField = "Test"
+
+	if err := testModel.SaveContext(ctx); err != context.Canceled {
+		t.Error("DB: expected canceled context error for save", err)
+	}
+}
+
 func TestRemove(t *testing.T) {
 
 	Test := dbConnection.Model("testmodel")
@@ -0,0 +1,192 @@
+package mongodm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//ProjectionMode defines how Save handles documents which were loaded with Query.Select (see: ModelConfig.Projection)
+type ProjectionMode int
+
+const (
+	ProjectionUpdate ProjectionMode = iota // only the selected fields are written, other changes are ignored (default)
+	ProjectionRefuse                       // Save returns a *ProjectionError
+)
+
+/*
+ProjectionError is returned by Save for a document which was loaded with Query.Select, if the model refuses to save
+such documents or the document has to be stored completely (e.g. after an upgrade).
+
+For example:
+	user := &models.User{}
+
+	err := User.FindId(id).Select(bson.M{"firstname": 1}).Exec(user)
+
+	err = user.Save() //*mongodm.ProjectionError with mongodm.ProjectionRefuse
+*/
+type ProjectionError struct {
+	*QueryError
+}
+
+//projection describes the fields of a document which was loaded with a selector
+type projection struct {
+	paths     []string
+	inclusion bool // paths are the selected fields, otherwise the excluded ones
+}
+
+//automaticKeys are maintained by DocumentBase and written for documents with a projection, too
+var automaticKeys = map[string]bool{"updatedAt": true, "deleted": true, "deletedAt": true, "version": true}
+
+//newProjection parses a selector like func projectDocument, nil is returned if no field is restricted
+func newProjection(selector interface{}) (*projection, error) {
+
+	if selector == nil {
+		return nil, nil
+	}
+
+	raw, err := rawDocument(selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := documentMap(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &projection{}
+
+	for key, value := range fields {
+
+		if key != "_id" && truthy(value) {
+			parsed.inclusion = true
+		}
+	}
+
+	for key, value := range fields {
+
+		if key != "_id" && truthy(value) == parsed.inclusion {
+			parsed.paths = append(parsed.paths, key)
+		}
+	}
+
+	if len(parsed.paths) == 0 {
+		return nil, nil
+	}
+
+	return parsed, nil
+}
+
+//covers reports whether Save may write the path, a parent of an excluded path would overwrite it
+func (self *projection) covers(path string) bool {
+
+	if automaticKeys[path] {
+		return true
+	}
+
+	for _, projected := range self.paths {
+
+		within := path == projected || strings.HasPrefix(path, projected+".")
+
+		if self.inclusion && within {
+			return true
+		} else if !self.inclusion && (within || strings.HasPrefix(projected, path+".")) {
+			return false
+		}
+	}
+
+	return !self.inclusion
+}
+
+//restrict removes all paths of the changes which Save must not write
+func (self *projection) restrict(changes bson.M) {
+
+	for path := range changes {
+
+		if !self.covers(path) {
+			delete(changes, path)
+		}
+	}
+}
+
+//projectionError returns the error for a document with projection which can not be saved
+func (self *DocumentBase) projectionError(reason string) error {
+
+	return &ProjectionError{&QueryError{fmt.Sprintf("DB: Document %v in collection '%v' was loaded with Select and %v, load it without Select to save it", self.Id.Hex(), self.model.name, reason)}}
+}
+
+//loaded prepares a decoded document for Save: the extras are captured and the projection of the query is recorded
+func (self *Query) loaded(document reflect.Value, raw bson.Raw) error {
+
+	if err := self.model.captureExtras(document, raw); err != nil {
+		return err
+	}
+
+	tracked, ok := document.Interface().(documentBase)
+
+	if !ok {
+		return nil
+	}
+
+	recorded, err := newProjection(self.selector)
+
+	if err != nil {
+		return err
+	}
+
+	tracked.base().projection = recorded
+
+	return nil
+}
+
+/*
+findSelector returns the selector of the query, an inclusion selector is extended by the keys which Save needs for a
+partial document: the version for models with versioning (see: ModelConfig.Versioning) and the schema version for
+models with upgrades, otherwise a current document would be upgraded again.
+*/
+func (self *Query) findSelector() interface{} {
+
+	keys := []string{}
+
+	if self.model.config.Versioning {
+		keys = append(keys, "version")
+	}
+
+	if self.model.SchemaVersion() > 0 {
+		keys = append(keys, "schemaVersion")
+	}
+
+	if len(keys) == 0 || self.selector == nil {
+		return self.selector
+	}
+
+	//invalid selectors are reported by the find operation
+	recorded, err := newProjection(self.selector)
+
+	if err != nil || recorded == nil || !recorded.inclusion {
+		return self.selector
+	}
+
+	raw, err := rawDocument(self.selector)
+
+	if err != nil {
+		return self.selector
+	}
+
+	fields, err := documentMap(raw)
+
+	if err != nil {
+		return self.selector
+	}
+
+	for _, key := range keys {
+		fields[key] = 1
+	}
+
+	return fields
+}
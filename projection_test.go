@@ -0,0 +1,172 @@
+package mongodm
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestProjectionModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	FirstName string `json:"firstname" bson:"firstname"`
+	LastName  string `json:"lastname" bson:"lastname"`
+	Age       int    `json:"age" bson:"age"`
+}
+
+func TestProjectionUpdate(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestProjectionModel{}, "projected"); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestProjectionModel")
+	document := &TestProjectionModel{}
+
+	model.New(document)
+
+	document.FirstName = "Max"
+	document.LastName = "Mustermann"
+	document.Age = 42
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	partial := &TestProjectionModel{}
+
+	if err := model.FindId(document.Id).Select(bson.M{"firstname": 1}).Exec(partial); err != nil || partial.LastName != "" {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	partial.FirstName = "Moritz"
+	partial.Age = 7
+
+	if err := partial.Save(); err != nil {
+		t.Fatal("DB: partial document could not be saved", err)
+	}
+
+	if count, _ := model.Find(bson.M{"firstname": "Moritz", "lastname": "Mustermann", "age": 42}).Count(); count != 1 {
+		t.Error("DB: save did not only write the selected fields")
+	}
+
+	//deleting a partial document works, too
+	if err := partial.Delete(); err != nil {
+		t.Fatal("DB: partial document could not be deleted", err)
+	}
+
+	if count, _ := model.Find(bson.M{"deleted": true, "lastname": "Mustermann"}).Count(); count != 1 {
+		t.Error("DB: partial document was not deleted")
+	}
+
+	//excluded fields are not written
+	if err := model.FindId(document.Id).WithDeleted().Select(bson.M{"lastname": 0}).Exec(partial); err != nil || partial.Age != 42 {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	partial.Age = 43
+	partial.LastName = "Musterfrau"
+
+	if err := partial.Restore(); err != nil {
+		t.Fatal("DB: partial document could not be restored", err)
+	}
+
+	if count, _ := model.Find(bson.M{"firstname": "Moritz", "lastname": "Mustermann", "age": 43}).Count(); count != 1 {
+		t.Error("DB: save wrote an excluded field")
+	}
+
+	//a partial document is never stored completely
+	model.collection.Remove(context.Background(), bson.M{"_id": document.Id})
+
+	if err := partial.Save(); err == nil {
+		t.Error("DB: expected not found error for a removed partial document")
+	}
+}
+
+func TestProjectionRefuse(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestProjectionModel{}, "projected", &ModelConfig{Projection: ProjectionRefuse}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestProjectionModel")
+	document := &TestProjectionModel{}
+
+	model.New(document)
+	document.FirstName = "Max"
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	if err := model.FindId(document.Id).Select(bson.M{"firstname": 1}).Exec(document); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	if err := document.Save(); err == nil {
+		t.Error("DB: expected projection error")
+	} else if _, ok := err.(*ProjectionError); !ok {
+		t.Error("DB: expected projection error", err)
+	}
+
+	//a complete load resets the projection
+	if err := model.FindId(document.Id).Exec(document); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	if err := document.Save(); err != nil {
+		t.Error("DB: complete document could not be saved", err)
+	}
+}
+
+func TestProjectionVersioning(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestProjectionModel{}, "projected", &ModelConfig{Versioning: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestProjectionModel")
+	document := &TestProjectionModel{}
+
+	model.New(document)
+
+	document.FirstName = "Max"
+	document.LastName = "Mustermann"
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	document.Age = 42
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	//the version is loaded without being selected
+	partial := &TestProjectionModel{}
+
+	if err := model.FindId(document.Id).Select(bson.M{"firstname": 1}).Exec(partial); err != nil || partial.Version != 2 {
+		t.Fatal("DB: version of partial document was not loaded", partial.Version, err)
+	}
+
+	partial.FirstName = "Moritz"
+
+	if err := partial.Save(); err != nil || partial.Version != 3 {
+		t.Fatal("DB: partial document could not be saved", partial.Version, err)
+	}
+
+	if count, _ := model.Find(bson.M{"firstname": "Moritz", "lastname": "Mustermann", "version": 3}).Count(); count != 1 {
+		t.Error("DB: partial document was not saved")
+	}
+}
@@ -1,10 +1,10 @@
 package mongodm
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -20,7 +20,7 @@ For Example:
 	User.Find(bson.M{"lastname":"Mustermann"}).Populate("Messages").Skip(10).Limit(5).Exec(&users)
 */
 type Query struct {
-	collection *mgo.Collection
+	model      *Model
 	connection *Connection
 	query      interface{}
 	selector   interface{}
@@ -28,6 +28,8 @@ type Query struct {
 	sort       []string
 	limit      int
 	skip       int
+	mode       Mode
+	deleted    deletedScope
 	multiple   bool
 }
 
@@ -63,10 +65,39 @@ func (self *Query) Skip(skip int) *Query {
 	return self
 }
 
+/*
+Mode overrides the session mode of the connection and model for this query and the population of its relations.
+
+For example:
+	reports := []*models.Report{}
+
+	Report.Find().Mode(mongodm.SecondaryPreferred).Exec(&reports) //analytic reads must not load the primary
+*/
+func (self *Query) Mode(mode Mode) *Query {
+
+	self.mode = mode
+
+	return self
+}
+
 //see: http://godoc.org/gopkg.in/mgo.v2#Query.Count
 func (self *Query) Count() (n int, err error) {
 
-	return self.collection.Find(self.query).Count()
+	return self.CountContext(context.Background())
+}
+
+//CountContext works like Count but is canceled as soon as the given context is done.
+func (self *Query) CountContext(ctx context.Context) (int, error) {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("Count on collection '%v'", self.model.name))
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer done()
+
+	return self.model.backendCollection(self.mode).Count(ctx, self.filter())
 }
 
 /*
@@ -106,10 +137,40 @@ func (self *Query) Populate(fields ...string) *Query {
 	return self
 }
 
+/*
+Exec runs the query and stores the matching documents in result. Pass a pointer to a slice of IDocumentBase types
+for Find and a single IDocumentBase type for FindOne and FindId.
+*/
 func (self *Query) Exec(result interface{}) error {
 
+	return self.ExecContext(context.Background(), result)
+}
+
+/*
+ExecContext works like Exec but passes the context to the database session and to the population of all
+relations. As soon as the context is done, the query gets aborted and the context error is returned.
+
+For example:
+
+	ctx, cancel := context.WithTimeout(request.Context(), 2*time.Second)
+	defer cancel()
+
+	users := []*models.User{}
+
+	err := User.Find(bson.M{"firstname": "Max"}).Populate("Messages").ExecContext(ctx, &users)
+*/
+func (self *Query) ExecContext(ctx context.Context, result interface{}) error {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("Exec on collection '%v'", self.model.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
 	if result == nil {
-		panic("DB: No result specified")
+		return self.connection.fail(&SchemaError{&QueryError{"DB: No result specified"}})
 	}
 
 	resultType := reflect.TypeOf(result)
@@ -122,80 +183,110 @@ func (self *Query) Exec(result interface{}) error {
 	if resultType.Kind() == reflect.Ptr && resultType.Elem().Kind() == reflect.Slice {
 
 		if !self.multiple {
-			panic("DB: Execution expected an IDocumentBase type!")
+			return self.connection.fail(&SchemaError{&QueryError{"DB: Execution expected an IDocumentBase type!"}})
+		}
+
+		if elementType := resultType.Elem().Elem(); !elementType.Implements(documentBaseType) {
+			return self.connection.fail(&SchemaError{&QueryError{fmt.Sprintf("DB: Execution expected a slice of IDocumentBase types, got '%v'", elementType)}})
 		}
 
 		/*
-		 *	single Query execution
+		 *	multiple Query execution
 		 */
 
-		mgoQuery := self.collection.Find(self.query)
+		raws, err := self.model.backendCollection(self.mode).Find(ctx, self.filter(), self.findOptions())
 
-		self.extendQuery(mgoQuery)
-
-		err := mgoQuery.All(result)
-
-		if err == mgo.ErrNotFound {
+		if err != nil {
+			return err
+		}
 
-			return &NotFoundError{&QueryError{fmt.Sprintf("No records found")}}
+		if raws, err = self.model.upgrade(raws); err != nil {
+			return err
+		}
 
-		} else if err != nil {
+		err = decodeSlice(raws, result)
 
+		if err != nil {
 			return err
+		}
 
-		} else {
+		slice := reflect.ValueOf(result).Elem()
 
-			slice := reflect.ValueOf(result).Elem()
+		for index := 0; index < slice.Len(); index++ {
 
-			for index := 0; index < slice.Len(); index++ {
+			current := slice.Index(index)
 
-				current := slice.Index(index)
+			if err := self.loaded(current, raws[index]); err != nil {
+				return err
+			}
+
+			self.initWithObjectId(current)
 
-				self.initWithObjectId(current)
-				self.initDocument(&current, &current, self.collection, self.connection)
-				err := self.runPopulation(current)
+			if err := self.initDocument(&current, self.model); err != nil {
+				return err
+			}
 
-				if err != nil {
+			err := self.runPopulation(ctx, current)
 
-					return err
-				}
+			if err != nil {
 
+				return err
 			}
 
 		}
-		//expect all other types - missmatch will panic later or through mgo adapter
+
+		//expect all other types - missmatch is reported as schema error
 	} else {
 
 		if self.multiple {
-			panic("DB: Execution expected a pointer to a slice!")
+			return self.connection.fail(&SchemaError{&QueryError{"DB: Execution expected a pointer to a slice!"}})
+		}
+
+		if !resultType.Implements(documentBaseType) {
+			return self.connection.fail(&SchemaError{&QueryError{fmt.Sprintf("DB: Execution expected an IDocumentBase type, got '%v'", resultType)}})
 		}
 
 		/*
-		 *	multiple Query execution
+		 *	single Query execution
 		 */
 
-		mgoQuery := self.collection.Find(self.query)
+		findOptions := self.findOptions()
+		findOptions.Limit = 1
 
-		self.extendQuery(mgoQuery)
+		raws, err := self.model.backendCollection(self.mode).Find(ctx, self.filter(), findOptions)
+
+		if err != nil {
 
-		err := mgoQuery.One(result)
+			return err
 
-		if err == mgo.ErrNotFound {
+		} else if len(raws) == 0 {
 
 			return &NotFoundError{&QueryError{fmt.Sprintf("No record found")}}
+		}
 
-		} else if err != nil {
-
+		if raws, err = self.model.upgrade(raws); err != nil {
 			return err
+		}
 
+		err = raws[0].Unmarshal(result)
+
+		if err != nil {
+			return err
 		}
 
 		value := reflect.ValueOf(result)
 
+		if err := self.loaded(value, raws[0]); err != nil {
+			return err
+		}
+
 		self.initWithObjectId(value)
-		self.initDocument(&value, &value, self.collection, self.connection)
 
-		err = self.runPopulation(value)
+		if err := self.initDocument(&value, self.model); err != nil {
+			return err
+		}
+
+		err = self.runPopulation(ctx, value)
 
 		if err != nil {
 
@@ -207,45 +298,77 @@ func (self *Query) Exec(result interface{}) error {
 	return nil
 }
 
-//extendQuery sets all native query options if specified
-func (self *Query) extendQuery(mgoQuery *mgo.Query) {
+//decodeSlice unmarshals raw documents into the slice result points to (like mgo.Query.All)
+func decodeSlice(raws []bson.Raw, result interface{}) error {
 
-	if self.selector != nil {
-		mgoQuery.Select(self.selector)
-	}
+	slice := reflect.ValueOf(result).Elem()
+	elementType := slice.Type().Elem()
+	buffer := reflect.MakeSlice(slice.Type(), len(raws), len(raws))
 
-	if len(self.sort) > 0 {
-		mgoQuery.Sort(self.sort...)
-	}
+	for index, raw := range raws {
+
+		element := buffer.Index(index)
+
+		if elementType.Kind() == reflect.Ptr {
+			element.Set(reflect.New(elementType.Elem()))
+		} else {
+			element = element.Addr()
+		}
 
-	if self.limit != 0 {
-		mgoQuery.Limit(self.limit)
+		if err := raw.Unmarshal(element.Interface()); err != nil {
+			return err
+		}
 	}
 
-	if self.skip != 0 {
-		mgoQuery.Skip(self.skip)
+	slice.Set(buffer)
+
+	return nil
+}
+
+//findOptions returns all native query options if specified
+func (self *Query) findOptions() *FindOptions {
+
+	return &FindOptions{
+		Selector: self.findSelector(),
+		Sort:     self.sort,
+		Limit:    self.limit,
+		Skip:     self.skip,
 	}
 }
 
 //runPopulation populates all specified fields with defined struct types
-func (self *Query) runPopulation(document reflect.Value) error {
+func (self *Query) runPopulation(ctx context.Context, document reflect.Value) error {
 	//iterate all specified population strings
+	documentSchema, err := schemaOf(document.Elem().Type())
+
+	if err != nil {
+		return self.connection.fail(err)
+	}
+
 	for _, populateFieldName := range self.populate {
 
+		//stop the fan-out as soon as the caller is gone
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		//check if the field name matches with a population
-		if structField, ok := document.Elem().Type().FieldByName(populateFieldName); ok {
+		if schemaField, ok := documentSchema.fieldsByName[populateFieldName]; ok {
 
-			modelTagValue := structField.Tag.Get("model")
+			modelTagValue := schemaField.model
 
 			//check if the relation model tag is set
 			if len(modelTagValue) == 0 {
-				panic(fmt.Sprintf("DB: Related model tag was not set for field '%v' in type '%v'", populateFieldName, document.Elem().Type().Name()))
+				return self.connection.fail(&SchemaError{&QueryError{fmt.Sprintf("DB: Related model tag was not set for field '%v' in type '%v'", populateFieldName, document.Elem().Type().Name())}})
 			}
 
 			//build the relation type
-			relatedModel := self.connection.Model(modelTagValue)
-			relatedDocument := self.connection.document(modelTagValue)
-			field := document.Elem().FieldByName(populateFieldName)
+			relatedModel, relatedDocument, err := self.connection.relatedModel(document.Elem().Type(), modelTagValue)
+
+			if err != nil {
+				return self.connection.fail(&SchemaError{&QueryError{fmt.Sprintf("DB: Related model '%v' of field '%v' is not registered", modelTagValue, populateFieldName)}})
+			}
+			field := document.Elem().Field(schemaField.index)
 
 			//check if the field is existent
 			if !field.IsNil() {
@@ -263,20 +386,31 @@ func (self *Query) runPopulation(document reflect.Value) error {
 
 					//find the matching document in the related collection
 					relatedId := fieldType
-					relationError := relatedModel.FindId(relatedId).Exec(relatedDocument)
+					relatedQuery := relatedModel.FindId(relatedId).Mode(self.mode)
+					relatedQuery.deleted = self.populationScope()
+
+					relationError := relatedQuery.ExecContext(ctx, relatedDocument)
 
-					if relationError == mgo.ErrNotFound && relationError != nil {
+					if _, notFound := relationError.(*NotFoundError); relationError != nil && !notFound {
 
 						//dont set/init anything here, because nil is the correct behaviour
 						return relationError
 
+					} else if notFound {
+
+						//the id stays, so saving the document keeps a relation which was deleted or removed
+						continue
+
 					} else {
 
 						//populate the field
 						value := reflect.ValueOf(relatedDocument)
 
 						self.initWithObjectId(value)
-						self.initDocument(&value, &value, relatedModel.Collection, relatedModel.connection)
+
+						if err := self.initDocument(&value, relatedModel); err != nil {
+							return err
+						}
 
 						field.Set(value)
 					}
@@ -293,9 +427,22 @@ func (self *Query) runPopulation(document reflect.Value) error {
 					resultSlicePtr := reflect.New(resultSlice.Type())
 
 					//find relation objects by searching for ids which match with entrys from id slice
-					relationError := relatedModel.Find(bson.M{"_id": bson.M{"$in": &idSliceInterface}}).Exec(resultSlicePtr.Interface())
+					relatedQuery := relatedModel.Find(bson.M{"_id": bson.M{"$in": &idSliceInterface}}).Mode(self.mode)
+					relatedQuery.deleted = self.populationScope()
+
+					relationError := relatedQuery.ExecContext(ctx, resultSlicePtr.Interface())
 
-					if relationError == mgo.ErrNotFound || resultSlice.Len() == 0 {
+					//deleted relations are skipped, but their ids must be saved again
+					if parent, ok := document.Interface().(documentBase); ok && relationError == nil {
+
+						if relatedQuery.deleted == excludeDeleted {
+							parent.base().hide(schemaField.name, idSliceInterface, resultSlicePtr.Elem())
+						} else {
+							delete(parent.base().hidden, schemaField.name)
+						}
+					}
+
+					if resultSlice.Len() == 0 {
 
 						//in this case it is strictly necessary to init an empty slice of the document type (nil wouldnt be correct)
 						field.Set(reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(relatedDocument)), 0, 0))
@@ -312,18 +459,21 @@ func (self *Query) runPopulation(document reflect.Value) error {
 							populatedChild := resultSlicePtr.Elem().Index(index)
 
 							self.initWithObjectId(populatedChild)
-							self.initDocument(&populatedChild, &populatedChild, relatedModel.Collection, relatedModel.connection)
+
+							if err := self.initDocument(&populatedChild, relatedModel); err != nil {
+								return err
+							}
 						}
 					}
 
 				default:
 
-					panic("DB: unknown type stored as relation - bson.ObjectId or []bson.ObjectId expected")
+					return self.connection.fail(&RelationError{&QueryError{"DB: unknown type stored as relation - bson.ObjectId or []bson.ObjectId expected"}, populateFieldName})
 				}
 			}
 
 		} else {
-			panic(fmt.Sprintf("DB: Can not populate field '%v' for type '%v'. Field not found.", populateFieldName, document.Elem().Type().Name()))
+			return self.connection.fail(&SchemaError{&QueryError{fmt.Sprintf("DB: Can not populate field '%v' for type '%v'. Field not found.", populateFieldName, document.Elem().Type().Name())}})
 		}
 	}
 
@@ -342,17 +492,21 @@ func (self *Query) initWithObjectId(document reflect.Value) {
 	if len(self.populate) == 0 {
 
 		structElement := document.Elem()
-		fieldType := structElement.Type()
+		documentSchema, err := schemaOf(structElement.Type())
+
+		//tag errors were already reported on registration
+		if err != nil {
+			return
+		}
 
 		//Iterate over all struct fields
-		for fieldIndex := 0; fieldIndex < structElement.NumField(); fieldIndex++ {
+		for _, schemaField := range documentSchema.fields {
 
-			relationTag := fieldType.Field(fieldIndex).Tag.Get("relation")
-			field := structElement.Field(fieldIndex)
+			field := structElement.Field(schemaField.index)
 
-			if len(relationTag) > 0 {
+			if len(schemaField.relation) > 0 {
 
-				if relationTag == REL_1N {
+				if schemaField.relation == REL_1N {
 
 					if field.IsNil() {
 
@@ -387,21 +541,28 @@ func (self *Query) initWithObjectId(document reflect.Value) {
 }
 
 //like Model.New(), only directly for reflect types
-func (self *Query) initDocument(model *reflect.Value, document *reflect.Value, collection *mgo.Collection, connection *Connection) {
+func (self *Query) initDocument(document *reflect.Value, model *Model) error {
 
-	documentMethod := model.MethodByName("SetDocument")
-	collectionMethod := model.MethodByName("SetCollection")
-	connectionMethod := model.MethodByName("SetConnection")
+	documentMethod := document.MethodByName("SetDocument")
+	modelMethod := document.MethodByName("SetModel")
+	connectionMethod := document.MethodByName("SetConnection")
 
-	if !documentMethod.IsValid() || !collectionMethod.IsValid() || !connectionMethod.IsValid() {
-		panic("Given models were not correctly initialized with 'DocumentBase' interface type")
+	if !documentMethod.IsValid() || !modelMethod.IsValid() || !connectionMethod.IsValid() {
+		return self.connection.fail(&SchemaError{&QueryError{"Given models were not correctly initialized with 'DocumentBase' interface type"}})
 	}
 
 	documentInput := []reflect.Value{*document}
-	collectionInput := []reflect.Value{reflect.ValueOf(collection)}
-	connectionInput := []reflect.Value{reflect.ValueOf(connection)}
+	modelInput := []reflect.Value{reflect.ValueOf(model)}
+	connectionInput := []reflect.Value{reflect.ValueOf(model.connection)}
 
 	documentMethod.Call(documentInput)
-	collectionMethod.Call(collectionInput)
+	modelMethod.Call(modelInput)
 	connectionMethod.Call(connectionInput)
+
+	//relations are not populated yet, so the snapshot contains their ids like the stored document
+	if tracked, ok := document.Interface().(documentBase); ok {
+		tracked.base().track()
+	}
+
+	return nil
 }
@@ -0,0 +1,167 @@
+package mongodm
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type TestQualifiedModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Relation interface{} `json:"relation" bson:"relation" model:"relations.Relation" relation:"11"`
+	Report   interface{} `json:"report" bson:"report" model:"reporting.Report" relation:"11"`
+}
+
+func TestRegistryConcurrentRegistration(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	var wait sync.WaitGroup
+
+	for index := 0; index < 20; index++ {
+
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			if err := db.Register(&TestReportModel{}, "reports"); err != nil {
+				t.Error("DB: registering the same type twice must not fail", err)
+			}
+
+			db.LookupModel("testreportmodel")
+		}()
+	}
+
+	wait.Wait()
+
+	if _, err := db.LookupModel("TestReportModel"); err != nil {
+		t.Error("DB: registered model was not found", err)
+	}
+}
+
+func TestRegistryConflicts(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestModel{}, "othercollection"); err == nil {
+		t.Error("DB: expected error for registration of a type for another collection")
+	} else if _, ok := err.(*RegistryError); !ok {
+		t.Error("DB: expected registry error", err)
+	}
+
+	if _, err := db.LookupModel("unknown"); err == nil {
+		t.Error("DB: expected error for unknown model")
+	} else if _, ok := err.(*RegistryError); !ok {
+		t.Error("DB: expected registry error", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("DB: expected panic for unknown model")
+		}
+	}()
+
+	db.Model("unknown")
+}
+
+func TestRegistryNamesAndAliases(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestReportModel{}, "reports", &ModelConfig{Name: "Report", Aliases: []string{"reporting.Report"}}); err != nil {
+		t.Fatal("DB: registration with name and aliases failed", err)
+	}
+
+	model, err := db.LookupModel("Report")
+
+	if err != nil {
+		t.Fatal("DB: model was not registered with its name", err)
+	}
+
+	//the import path depends on the checkout (e.g. "mongodm" inside docker)
+	qualifiedName := reflect.TypeOf(TestReportModel{}).PkgPath() + ".TestReportModel"
+
+	for _, name := range []string{"reporting.Report", qualifiedName} {
+
+		if aliased, err := db.LookupModel(name); err != nil || aliased != model {
+			t.Error("DB: model was not registered with alias", name, err)
+		}
+	}
+
+	if _, err := db.LookupModel("TestReportModel"); err == nil {
+		t.Error("DB: explicit name must replace the type name")
+	}
+
+	if err := db.Register(&TestRelationModel{}, DBTestRelCollection, &ModelConfig{Name: "Report"}); err == nil {
+		t.Error("DB: expected error for name registered by another type")
+	} else if _, ok := err.(*RegistryError); !ok {
+		t.Error("DB: expected registry error", err)
+	}
+
+	if _, err := db.LookupModel("Report"); err != nil {
+		t.Error("DB: conflicting registration must not change the registry", err)
+	}
+}
+
+func TestRegistryQualifiedRelations(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Register(&TestReportModel{}, "reports", &ModelConfig{Name: "Report", Aliases: []string{"reporting.Report"}})
+	db.Register(&TestRelationModel{}, DBTestRelCollection, &ModelConfig{Aliases: []string{"relations.Relation"}})
+	db.Register(&TestQualifiedModel{}, "qualified")
+
+	//the qualified name resolves like the alias of the relation
+	qualifiedName := reflect.TypeOf(TestRelationModel{}).PkgPath() + ".TestRelationModel"
+
+	if aliased, err := db.LookupModel(qualifiedName); err != nil || aliased != db.Model("relations.Relation") {
+		t.Error("DB: model was not registered with its qualified name", qualifiedName, err)
+	}
+
+	relation := &TestRelationModel{}
+	report := &TestReportModel{}
+
+	db.Model("TestRelationModel").New(relation)
+	db.Model("Report").New(report)
+
+	report.Title = "Quarterly"
+
+	if err := relation.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	if err := report.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	qualified := &TestQualifiedModel{}
+
+	db.Model("TestQualifiedModel").New(qualified)
+
+	qualified.Relation = relation
+	qualified.Report = report
+
+	if err := qualified.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	found := &TestQualifiedModel{}
+
+	if err := db.Model("TestQualifiedModel").FindId(qualified.Id).Populate("Relation", "Report").Exec(found); err != nil {
+		t.Fatal("DB: population with qualified model names failed", err)
+	}
+
+	if _, ok := found.Relation.(*TestRelationModel); !ok {
+		t.Error("DB: relation with aliased model tag was not populated", found.Relation)
+	}
+
+	if populated, ok := found.Report.(*TestReportModel); !ok || populated.Title != "Quarterly" {
+		t.Error("DB: relation with aliased model tag was not populated", found.Report)
+	}
+}
@@ -0,0 +1,208 @@
+package mongodm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//customRegex detects a custom regular expression in the validation tag, e.g. validation:"/^[a-z]+$/i"
+var customRegex = regexp.MustCompile(`^/(.+)/([gim]*)$`)
+
+//schemaCache stores the schema of each document type, it is filled on registration or on first use
+var schemaCache sync.Map
+
+/*
+schema is the parsed description of a document type. It is built once per type (see: func (*Connection) Register),
+so validation, saving and population don't have to parse the struct tags on each call.
+*/
+type schema struct {
+	documentType reflect.Type
+	fields       []*schemaField
+	fieldsByName map[string]*schemaField
+	indexes      []Index // indexes declared with index tags
+}
+
+//schemaField contains the parsed tags of a struct field
+type schemaField struct {
+	index          int
+	name           string // name of the struct field
+	bsonName       string // name of the document key
+	inline         bool
+	validationName string // name used in validation messages (json name)
+
+	minLen     int
+	maxLen     int
+	required   bool
+	validation string         // lower case value of the validation tag
+	regex      *regexp.Regexp // compiled custom regular expression of the validation tag
+
+	model    string // related model of a relation
+	relation string // relation tag, e.g. "11" or "1n"
+	autoSave bool
+
+	indexes []indexDeclaration // parsed index tag
+}
+
+//isRelation reports if the field stores a relation (a model tag is set)
+func (self *schemaField) isRelation() bool {
+
+	return len(self.model) > 0
+}
+
+//relationType returns the relation type of the field, one-to-one is the default
+func (self *schemaField) relationType() string {
+
+	if self.relation == REL_1N {
+		return REL_1N
+	}
+
+	return REL_11
+}
+
+//schemaOf returns the cached schema of a document type (a struct or a pointer to a struct)
+func schemaOf(documentType reflect.Type) (*schema, error) {
+
+	if documentType.Kind() == reflect.Ptr {
+		documentType = documentType.Elem()
+	}
+
+	if cached, ok := schemaCache.Load(documentType); ok {
+		return cached.(*schema), nil
+	}
+
+	parsed, err := parseSchema(documentType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cached, _ := schemaCache.LoadOrStore(documentType, parsed)
+
+	return cached.(*schema), nil
+}
+
+//parseSchema parses the tags of all struct fields, invalid tags are reported as *SchemaError
+func parseSchema(documentType reflect.Type) (*schema, error) {
+
+	if documentType.Kind() != reflect.Struct {
+		return nil, &SchemaError{&QueryError{fmt.Sprintf("DB: Type '%v' is no struct", documentType)}}
+	}
+
+	parsed := &schema{
+		documentType: documentType,
+		fields:       make([]*schemaField, 0, documentType.NumField()),
+		fieldsByName: make(map[string]*schemaField, documentType.NumField()),
+	}
+
+	for fieldIndex := 0; fieldIndex < documentType.NumField(); fieldIndex++ {
+
+		structField := documentType.Field(fieldIndex)
+		fieldTag := structField.Tag
+
+		field := &schemaField{
+			index:      fieldIndex,
+			name:       structField.Name,
+			validation: strings.ToLower(fieldTag.Get("validation")),
+			model:      fieldTag.Get("model"),
+			relation:   fieldTag.Get("relation"),
+		}
+
+		tagError := func(tag string, expected string) error {
+			return &SchemaError{&QueryError{fmt.Sprintf("DB: Check your %v tag of field '%v' in type '%v' - must be %v", tag, structField.Name, documentType.Name(), expected)}}
+		}
+
+		var err error
+
+		if minLenTag := fieldTag.Get("minLen"); len(minLenTag) > 0 {
+
+			if field.minLen, err = strconv.Atoi(minLenTag); err != nil {
+				return nil, tagError("minLen", "numeric")
+			}
+		}
+
+		if maxLenTag := fieldTag.Get("maxLen"); len(maxLenTag) > 0 {
+
+			if field.maxLen, err = strconv.Atoi(maxLenTag); err != nil {
+				return nil, tagError("maxLen", "numeric")
+			}
+		}
+
+		if requiredTag := fieldTag.Get("required"); len(requiredTag) > 0 {
+
+			if field.required, err = strconv.ParseBool(requiredTag); err != nil {
+				return nil, tagError("required", "boolean")
+			}
+		}
+
+		if autoSaveTag := fieldTag.Get("autosave"); len(autoSaveTag) > 0 {
+
+			if field.autoSave, err = strconv.ParseBool(autoSaveTag); err != nil {
+				return nil, tagError("autosave", "boolean")
+			}
+		}
+
+		if len(field.relation) > 0 && field.relation != REL_11 && field.relation != REL_1N {
+			return nil, tagError("relation", "'11' or '1n'")
+		}
+
+		// the pattern is compiled from the original tag, because lower case changes the meaning (e.g. \S and \s)
+		if matches := customRegex.FindStringSubmatch(fieldTag.Get("validation")); matches != nil {
+
+			flags := strings.Replace(matches[2], "g", "", -1) // global matching has no meaning for validation
+			pattern := matches[1]
+
+			if len(flags) > 0 {
+				pattern = "(?" + flags + ")" + pattern
+			}
+
+			if field.regex, err = regexp.Compile(pattern); err != nil {
+				return nil, tagError("validation", fmt.Sprintf("a valid regular expression (%v)", err))
+			}
+		}
+
+		// mgo uses the lower case field name if no key was set
+		bsonTag := strings.Split(fieldTag.Get("bson"), ",")
+		field.bsonName = bsonTag[0]
+
+		if len(field.bsonName) == 0 {
+			field.bsonName = strings.ToLower(structField.Name)
+		}
+
+		for _, flag := range bsonTag[1:] {
+
+			if flag == "inline" {
+				field.inline = true
+			}
+		}
+
+		if indexTag, ok := fieldTag.Lookup("index"); ok {
+
+			if field.indexes, err = parseIndexTag(indexTag); err != nil {
+				return nil, tagError("index", err.Error())
+			}
+		}
+
+		field.validationName = strings.Split(fieldTag.Get("json"), ",")[0]
+
+		if field.validationName == "-" {
+			field.validationName = strings.ToLower(structField.Name)
+		}
+
+		parsed.fields = append(parsed.fields, field)
+		parsed.fieldsByName[structField.Name] = field
+	}
+
+	indexes, err := schemaIndexes(parsed)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsed.indexes = indexes
+
+	return parsed, nil
+}
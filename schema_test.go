@@ -0,0 +1,76 @@
+package mongodm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type TestInvalidTagModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name string `json:"name" bson:"name" minLen:"two"`
+}
+
+type TestRegexModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Code string `json:"code" bson:"productCode" validation:"/^[A-Z]{2}\\S+$/i"`
+}
+
+func TestSchemaTagErrorsOnRegister(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestInvalidTagModel{}, "invalid"); err == nil {
+		t.Error("DB: expected schema error for invalid minLen tag")
+	} else if _, ok := err.(*SchemaError); !ok {
+		t.Error("DB: expected schema error for invalid minLen tag", err)
+	}
+
+	if _, err := db.LookupModel("TestInvalidTagModel"); err == nil {
+		t.Error("DB: model with invalid tags must not be registered")
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+
+	first, err := schemaOf(reflect.TypeOf(&TestRegexModel{}))
+
+	if err != nil {
+		t.Fatal("DB: schema could not be parsed", err)
+	}
+
+	second, _ := schemaOf(reflect.TypeOf(TestRegexModel{}))
+
+	if first != second {
+		t.Error("DB: schema was parsed twice")
+	}
+
+	field := first.fieldsByName["Code"]
+
+	if field.bsonName != "productCode" || field.validationName != "code" || field.regex == nil {
+		t.Error("DB: field tags were not parsed correctly", field)
+	}
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Register(&TestRegexModel{}, "regex")
+
+	document := &TestRegexModel{}
+
+	db.Model("TestRegexModel").New(document)
+
+	document.Code = "ab-1234"
+
+	if valid, issues := document.Validate(); !valid {
+		t.Error("DB: expected valid code for case insensitive regular expression", issues)
+	}
+
+	document.Code = "ab 1234"
+
+	if valid, _ := document.Validate(); valid {
+		t.Error("DB: expected invalid code for regular expression")
+	}
+}
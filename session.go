@@ -0,0 +1,118 @@
+package mongodm
+
+import (
+	"time"
+)
+
+/*
+Mode is the consistency mode of a session, see: http://godoc.org/labix.org/v2/mgo#Session.SetMode
+It can be set for the whole connection (Config.Mode), for a model (ModelConfig.Mode) or for a single query (func (*Query) Mode).
+*/
+type Mode int
+
+const (
+	DefaultMode        Mode = iota // inherit the mode of the connection or model
+	Strong                         // reads and writes on the primary only
+	Monotonic                      // reads may go to a secondary until the first write, then switch to the primary
+	Eventual                       // reads from any secondary, writes on the primary
+	Primary                        // read preference "primary" (same as Strong)
+	PrimaryPreferred               // read preference "primaryPreferred"
+	Secondary                      // read preference "secondary"
+	SecondaryPreferred             // read preference "secondaryPreferred"
+	Nearest                        // read preference "nearest"
+)
+
+var modeByReadPreference = map[string]Mode{
+	"primary":            Primary,
+	"primaryPreferred":   PrimaryPreferred,
+	"secondary":          Secondary,
+	"secondaryPreferred": SecondaryPreferred,
+	"nearest":            Nearest,
+}
+
+/*
+Safe is the write concern of a session, see: http://godoc.org/labix.org/v2/mgo#Safe
+A nil value keeps the default of the driver (acknowledged writes).
+*/
+type Safe struct {
+	W        int           // minimum number of servers which have to acknowledge the write
+	WMode    string        // write mode, e.g. "majority" (takes precedence over W)
+	WTimeout time.Duration // time to wait for W before the write fails
+	J        bool          // wait until the write was committed to the journal
+	FSync    bool          // wait until the data files were synced (or the journal if present)
+}
+
+//SessionOptions overrides the session settings of the connection for a collection handle (see: Backend)
+type SessionOptions struct {
+	Mode Mode
+	Safe *Safe
+}
+
+//sessionSettings contains the session settings of a connection, resolved from the config, the URI and the defaults
+type sessionSettings struct {
+	mode          Mode
+	safe          *Safe
+	dialTimeout   time.Duration
+	socketTimeout time.Duration
+	syncTimeout   time.Duration
+	poolLimit     int
+}
+
+//resolveSessionSettings merges the session settings, config attributes take precedence over URI options and dial info (uri may be nil)
+func resolveSessionSettings(config *Config, uri *ConnectionURI) sessionSettings {
+
+	settings := sessionSettings{
+		mode:        Monotonic, // default mode of the ODM since the first release
+		dialTimeout: 3 * time.Second,
+	}
+
+	if uri != nil {
+
+		if len(uri.ReadPreference) > 0 {
+			settings.mode = modeByReadPreference[uri.ReadPreference]
+		}
+
+		if uri.ConnectTimeout > 0 {
+			settings.dialTimeout = uri.ConnectTimeout
+		}
+
+		settings.safe = uri.Safe
+		settings.socketTimeout = uri.SocketTimeout
+		settings.syncTimeout = uri.ServerSelectionTimeout
+		settings.poolLimit = uri.MaxPoolSize
+
+	} else if config.DialInfo != nil {
+
+		if config.DialInfo.Timeout > 0 {
+			settings.dialTimeout = config.DialInfo.Timeout
+		}
+
+		settings.poolLimit = config.DialInfo.PoolLimit
+	}
+
+	if config.Mode != DefaultMode {
+		settings.mode = config.Mode
+	}
+
+	if config.Safe != nil {
+		settings.safe = config.Safe
+	}
+
+	if config.DialTimeout > 0 {
+		settings.dialTimeout = config.DialTimeout
+	}
+
+	if config.SocketTimeout > 0 {
+		settings.socketTimeout = config.SocketTimeout
+	}
+
+	if config.SyncTimeout > 0 {
+		settings.syncTimeout = config.SyncTimeout
+	}
+
+	if config.PoolLimit > 0 {
+		settings.poolLimit = config.PoolLimit
+	}
+
+	return settings
+}
@@ -0,0 +1,69 @@
+package mongodm
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestReportModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Title string `json:"title" bson:"title"`
+}
+
+func TestResolveSessionSettings(t *testing.T) {
+
+	settings := resolveSessionSettings(&Config{}, nil)
+
+	if settings.mode != Monotonic || settings.dialTimeout != 3*time.Second || settings.safe != nil {
+		t.Error("DB: default session settings are wrong", settings)
+	}
+
+	uri, err := ParseURI("mongodb://localhost/?readPreference=secondary&connectTimeoutMS=1000&socketTimeoutMS=2000&maxPoolSize=5&w=2")
+
+	if err != nil {
+		t.Fatal("DB: valid URI could not be parsed", err)
+	}
+
+	settings = resolveSessionSettings(&Config{}, uri)
+
+	if settings.mode != Secondary || settings.dialTimeout != time.Second || settings.socketTimeout != 2*time.Second || settings.poolLimit != 5 || settings.safe.W != 2 {
+		t.Error("DB: URI options were not applied", settings)
+	}
+
+	settings = resolveSessionSettings(&Config{Mode: Strong, DialTimeout: 5 * time.Second, PoolLimit: 10, Safe: &Safe{WMode: "majority"}}, uri)
+
+	if settings.mode != Strong || settings.dialTimeout != 5*time.Second || settings.poolLimit != 10 || settings.safe.WMode != "majority" {
+		t.Error("DB: config attributes must take precedence over URI options", settings)
+	}
+
+	if settings.socketTimeout != 2*time.Second {
+		t.Error("DB: unset config attributes must keep the URI options", settings)
+	}
+}
+
+func TestSessionModeOverrides(t *testing.T) {
+
+	if _, err := Connect(&Config{Driver: DriverMemory, Mode: Nearest + 1}); err == nil {
+		t.Error("DB: expected error for unknown mode")
+	}
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Register(&TestReportModel{}, "reports", &ModelConfig{Mode: Eventual, Safe: &Safe{W: 1}})
+
+	if model := db.Model("testreportmodel"); model.config.Mode != Eventual || model.config.Safe.W != 1 {
+		t.Error("DB: model config was not applied", model.config)
+	}
+
+	saveTestModel(t, db, "Alpha", 1)
+
+	count, err := db.Model("testmodel").Find(bson.M{"name": "Alpha"}).Mode(SecondaryPreferred).Count()
+
+	if err != nil || count != 1 {
+		t.Error("DB: Count with query mode failed", count, err)
+	}
+}
@@ -0,0 +1,149 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrShutdown is returned for operations which were started after func (*Connection) Shutdown was called.
+var ErrShutdown = errors.New("DB: The connection is shutting down")
+
+//ShutdownError is returned from func (*Connection) Shutdown if operations had to be canceled.
+type ShutdownError struct {
+	*QueryError
+	Operations []string // descriptions of the canceled operations, e.g. "Save on collection 'users' (running for 2s)"
+}
+
+type operationKey struct{}
+
+//operation is an ODM call which is in progress
+type operation struct {
+	description string
+	started     time.Time
+	cancel      context.CancelFunc
+}
+
+//operationTracker keeps track of all ODM calls which are in progress, so they can be drained on shutdown
+type operationTracker struct {
+	mutex      sync.Mutex
+	shutdown   bool
+	next       uint64
+	operations map[uint64]*operation
+	drained    chan struct{} // closed when no operations are left after the shutdown was started
+}
+
+/*
+begin registers an ODM call. Nested calls (e.g. autosaved children or populated relations) belong to the outer call and
+are not registered again, so a call which was admitted before the shutdown is never cut off halfway by the shutdown itself.
+The returned context is canceled if the shutdown times out, done must be called as soon as the call returned.
+*/
+func (self *Connection) begin(ctx context.Context, description string) (context.Context, func(), error) {
+
+	if ctx.Value(operationKey{}) != nil {
+		return ctx, func() {}, nil
+	}
+
+	tracker := &self.rootConnection().operations
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if tracker.shutdown {
+		return ctx, func() {}, ErrShutdown
+	}
+
+	if tracker.operations == nil {
+		tracker.operations = make(map[uint64]*operation)
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, operationKey{}, true))
+
+	tracker.next++
+	id := tracker.next
+
+	tracker.operations[id] = &operation{description, time.Now(), cancel}
+
+	done := func() {
+
+		cancel()
+
+		tracker.mutex.Lock()
+		defer tracker.mutex.Unlock()
+
+		delete(tracker.operations, id)
+
+		if tracker.shutdown && len(tracker.operations) == 0 && tracker.drained != nil {
+			close(tracker.drained)
+			tracker.drained = nil
+		}
+	}
+
+	return ctx, done, nil
+}
+
+/*
+Shutdown closes the connection gracefully. New operations are rejected with ErrShutdown, operations in progress
+can finish until the context is done. Operations which are still running afterwards get canceled and are listed
+in the returned *ShutdownError. Calling Shutdown on a tenant view shuts down the connection it was created from.
+
+For example:
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := connection.Shutdown(ctx); err != nil {
+		log.Printf("database shutdown: %v", err)
+	}
+*/
+func (self *Connection) Shutdown(ctx context.Context) error {
+
+	root := self.rootConnection()
+	tracker := &root.operations
+
+	tracker.mutex.Lock()
+
+	tracker.shutdown = true
+	drained := make(chan struct{})
+
+	if len(tracker.operations) == 0 {
+		close(drained)
+	} else {
+		tracker.drained = drained
+	}
+
+	tracker.mutex.Unlock()
+
+	var canceled []string
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+
+		tracker.mutex.Lock()
+
+		for _, operation := range tracker.operations {
+			operation.cancel()
+			canceled = append(canceled, fmt.Sprintf("%v (running for %v)", operation.description, time.Since(operation.started).Round(time.Millisecond)))
+		}
+
+		tracker.mutex.Unlock()
+	}
+
+	root.Close()
+
+	if len(canceled) > 0 {
+
+		sort.Strings(canceled)
+
+		return &ShutdownError{
+			&QueryError{fmt.Sprintf("DB: %v operation(s) canceled on shutdown: %v", len(canceled), strings.Join(canceled, ", "))},
+			canceled,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//blockingBackend blocks all find operations until release is closed or the context is done
+type blockingBackend struct {
+	Backend
+	started chan struct{}
+	release chan struct{}
+}
+
+type blockingCollection struct {
+	BackendCollection
+	backend *blockingBackend
+}
+
+func (self *blockingBackend) Collection(database string, name string, options *SessionOptions) BackendCollection {
+
+	return &blockingCollection{self.Backend.Collection(database, name, options), self}
+}
+
+func (self *blockingCollection) Find(ctx context.Context, filter interface{}, options *FindOptions) ([]bson.Raw, error) {
+
+	self.backend.started <- struct{}{}
+
+	select {
+	case <-self.backend.release:
+		return self.BackendCollection.Find(ctx, filter, options)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func blockingConnection(t *testing.T) (*Connection, *blockingBackend) {
+
+	db, err := Connect(&Config{Driver: DriverMemory})
+
+	if err != nil {
+		t.Fatal("DB: Connection error", err)
+	}
+
+	backend := &blockingBackend{db.backend, make(chan struct{}, 1), make(chan struct{})}
+	db.backend = backend
+
+	db.Register(&TestRelationModel{}, DBTestRelCollection)
+
+	return db, backend
+}
+
+func TestShutdownDrainsOperations(t *testing.T) {
+
+	db, backend := blockingConnection(t)
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- db.Model("TestRelationModel").Find().Exec(&[]*TestRelationModel{})
+	}()
+
+	<-backend.started
+
+	shutdown := make(chan error, 1)
+
+	go func() {
+		shutdown <- db.Shutdown(context.Background())
+	}()
+
+	//wait until the shutdown was started
+	for {
+
+		db.operations.mutex.Lock()
+		started := db.operations.shutdown
+		db.operations.mutex.Unlock()
+
+		if started {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := db.Model("TestRelationModel").Find().Count(); err != ErrShutdown {
+		t.Error("DB: expected new operations to be rejected", err)
+	}
+
+	close(backend.release)
+
+	if err := <-result; err != nil {
+		t.Error("DB: operation in progress was not finished", err)
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Error("DB: expected graceful shutdown", err)
+	}
+
+	if db.State() != StateClosed {
+		t.Error("DB: connection was not closed", db.State())
+	}
+}
+
+func TestShutdownCancelsOperations(t *testing.T) {
+
+	db, backend := blockingConnection(t)
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- db.Model("TestRelationModel").Find().Exec(&[]*TestRelationModel{})
+	}()
+
+	<-backend.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := db.Shutdown(ctx)
+
+	var shutdownError *ShutdownError
+
+	if !errors.As(err, &shutdownError) || len(shutdownError.Operations) != 1 {
+		t.Fatal("DB: expected shutdown error with the canceled operation", err)
+	}
+
+	if !strings.HasPrefix(shutdownError.Operations[0], "Exec on collection '"+DBTestRelCollection+"'") {
+		t.Error("DB: canceled operation was not described", shutdownError.Operations)
+	}
+
+	if err := <-result; err != context.Canceled {
+		t.Error("DB: expected the operation to be canceled", err)
+	}
+}
@@ -0,0 +1,322 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//deletedScope defines which documents a query considers regarding the soft delete flag (see: DocumentBase.Delete)
+type deletedScope int
+
+const (
+	excludeDeleted deletedScope = iota
+	includeDeleted
+	onlyDeleted
+)
+
+/*
+WithDeleted includes soft deleted documents in the query result. By default Find, FindOne, FindId and Count skip
+all documents which were deleted with Delete(). The relations of the query are also populated with deleted documents.
+
+For example:
+	users := []*models.User{}
+
+	User.Find(bson.M{"lastname": "Mustermann"}).WithDeleted().Exec(&users)
+*/
+func (self *Query) WithDeleted() *Query {
+
+	self.deleted = includeDeleted
+
+	return self
+}
+
+/*
+OnlyDeleted restricts the query result to soft deleted documents, e.g. to show a trash which can be restored.
+
+For example:
+	user := &models.User{}
+
+	err := User.FindId(id).OnlyDeleted().Exec(user)
+
+	if err == nil {
+		err = user.Restore()
+	}
+*/
+func (self *Query) OnlyDeleted() *Query {
+
+	self.deleted = onlyDeleted
+
+	return self
+}
+
+/*
+filter returns the query with the soft delete condition. Documents without deleted flag count as not deleted.
+A query which checks the "deleted" key itself is used as it is, so existing filters like bson.M{"deleted": false}
+keep working.
+*/
+func (self *Query) filter() interface{} {
+
+	var condition bson.M
+
+	switch self.deleted {
+
+	case includeDeleted:
+		return self.query
+
+	case onlyDeleted:
+		condition = bson.M{"deleted": true}
+
+	default:
+
+		if hasFilterKey(self.query, "deleted") {
+			return self.query
+		}
+
+		condition = bson.M{"deleted": bson.M{"$ne": true}}
+	}
+
+	if isEmptyFilter(self.query) {
+		return condition
+	}
+
+	return bson.M{"$and": []interface{}{self.query, condition}}
+}
+
+//populationScope returns the scope for relations: deleted relations are only populated if the query includes deleted documents
+func (self *Query) populationScope() deletedScope {
+
+	if self.deleted == excludeDeleted {
+		return excludeDeleted
+	}
+
+	return includeDeleted
+}
+
+//hasFilterKey reports whether a filter map or bson.D has the given top level key
+func hasFilterKey(filter interface{}, key string) bool {
+
+	if document, ok := filter.(bson.D); ok {
+
+		for _, element := range document {
+
+			if element.Name == key {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	value := reflect.ValueOf(filter)
+
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return false
+	}
+
+	return value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key())).IsValid()
+}
+
+//isEmptyFilter reports whether a filter matches all documents
+func isEmptyFilter(filter interface{}) bool {
+
+	if filter == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(filter)
+
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice:
+		return value.Len() == 0
+	}
+
+	return false
+}
+
+func (self *DocumentBase) GetDeletedAt() time.Time {
+	return self.DeletedAt
+}
+
+/*
+Restore reverts a soft delete (see: Delete). The deleted flag and the deletion time are reset and the document is saved.
+
+For example:
+	user := &models.User{}
+
+	err := User.FindId(id).WithDeleted().Exec(user)
+
+	if err == nil && user.IsDeleted() {
+		err = user.Restore()
+	}
+*/
+func (self *DocumentBase) Restore() error {
+
+	return self.RestoreContext(context.Background())
+}
+
+//RestoreContext works like Restore but passes the context to the underlying save operation.
+func (self *DocumentBase) RestoreContext(ctx context.Context) error {
+
+	if self.Id.Valid() {
+
+		self.SetDeleted(false)
+		self.DeletedAt = time.Time{}
+
+		return self.SaveContext(ctx)
+	}
+
+	return errors.New("Invalid object id")
+}
+
+/*
+HardDelete removes the document from the database, unlike Delete which only sets the deleted flag.
+A *NotFoundError is returned if the document does not exist (anymore).
+
+For example:
+	err := user.HardDelete() //e.g. the user requested the erasure of all personal data
+*/
+func (self *DocumentBase) HardDelete() error {
+
+	return self.HardDeleteContext(context.Background())
+}
+
+//HardDeleteContext works like HardDelete but is canceled as soon as the given context is done.
+func (self *DocumentBase) HardDeleteContext(ctx context.Context) error {
+
+	if self.document == nil || self.model == nil || self.connection == nil {
+		panic("You have to initialize your document with *Model.New(document IDocumentBase) before using HardDelete()!")
+	}
+
+	if !self.Id.Valid() {
+		return errors.New("Invalid object id")
+	}
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("HardDelete on collection '%v'", self.model.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	removed, err := self.model.collection.Remove(ctx, bson.M{"_id": self.Id})
+
+	if err != nil {
+		return err
+	} else if removed == 0 {
+		return &NotFoundError{&QueryError{fmt.Sprintf("No record found")}}
+	}
+
+	return nil
+}
+
+/*
+Purge removes soft deleted documents from the database and returns their number. Like Find, it accepts no or one
+query param to select the documents, only deleted documents are removed in any case.
+
+For example:
+	//remove everything which is in the trash for more than 30 days
+	removed, err := User.Purge(bson.M{"deletedAt": bson.M{"$lt": time.Now().AddDate(0, 0, -30)}})
+*/
+func (self *Model) Purge(query ...interface{}) (int, error) {
+
+	return self.PurgeContext(context.Background(), query...)
+}
+
+//PurgeContext works like Purge but is canceled as soon as the given context is done.
+func (self *Model) PurgeContext(ctx context.Context, query ...interface{}) (int, error) {
+
+	filter := self.Find(query...).OnlyDeleted().filter()
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("Purge on collection '%v'", self.name))
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer done()
+
+	return self.collection.Remove(ctx, filter)
+}
+
+//hiddenIds holds the ids of deleted relations by field name, which were skipped by the population (see: Query.WithDeleted)
+type hiddenIds map[string][]hiddenId
+
+//hiddenId is the id of a deleted relation and its position in the stored id list
+type hiddenId struct {
+	position int
+	id       bson.ObjectId
+}
+
+//hide remembers the ids of a populated one-to-many relation which have no populated document
+func (self *DocumentBase) hide(field string, ids []interface{}, populated reflect.Value) {
+
+	found := map[bson.ObjectId]bool{}
+
+	for index := 0; index < populated.Len(); index++ {
+
+		if document, ok := populated.Index(index).Interface().(IDocumentBase); ok {
+			found[document.GetId()] = true
+		}
+	}
+
+	hidden := []hiddenId{}
+
+	for position, value := range ids {
+
+		if id, ok := value.(bson.ObjectId); ok && !found[id] {
+			hidden = append(hidden, hiddenId{position, id})
+		}
+	}
+
+	if len(hidden) == 0 {
+		delete(self.hidden, field)
+		return
+	}
+
+	if self.hidden == nil {
+		self.hidden = hiddenIds{}
+	}
+
+	self.hidden[field] = hidden
+}
+
+//restore inserts the hidden ids of the field at their former positions into the ids which are stored
+func (self hiddenIds) restore(field string, ids []bson.ObjectId) []bson.ObjectId {
+
+	hidden := self[field]
+
+	if len(hidden) == 0 {
+		return ids
+	}
+
+	restored := append(make([]bson.ObjectId, 0, len(ids)+len(hidden)), ids...)
+
+	for _, relation := range hidden {
+
+		contained := false
+
+		for _, id := range restored {
+			contained = contained || id == relation.id
+		}
+
+		if contained {
+			continue
+		}
+
+		position := relation.position
+
+		if position > len(restored) {
+			position = len(restored)
+		}
+
+		restored = append(restored[:position], append([]bson.ObjectId{relation.id}, restored[position:]...)...)
+	}
+
+	return restored
+}
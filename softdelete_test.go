@@ -0,0 +1,172 @@
+package mongodm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSoftDelete(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+	TestRelation := db.Model("testrelationmodel")
+
+	relations := []bson.ObjectId{}
+
+	for _, name := range []string{"Kept", "Deleted"} {
+
+		relation := &TestRelationModel{}
+
+		TestRelation.New(relation)
+		relation.RelationName = name
+
+		if err := relation.Save(); err != nil {
+			t.Fatal("DB: creation error", err)
+		}
+
+		if name == "Deleted" {
+			relation.Delete()
+		}
+
+		relations = append(relations, relation.Id)
+	}
+
+	kept := saveTestModel(t, db, "Kept", 1)
+	deleted := saveTestModel(t, db, "Deleted", 2)
+
+	if err := deleted.Delete(); err != nil || deleted.DeletedAt.IsZero() {
+		t.Fatal("DB: document could not be deleted", err)
+	}
+
+	//documents without deleted flag are not deleted
+	Test.collection.Insert(context.Background(), bson.M{"_id": bson.NewObjectId(), "name": "Legacy", "number": 3})
+
+	if count, err := Test.Find().Count(); err != nil || count != 2 {
+		t.Error("DB: deleted documents were not excluded", count, err)
+	}
+
+	if count, _ := Test.Find(bson.M{"number": bson.M{"$gte": 2}}).WithDeleted().Count(); count != 2 {
+		t.Error("DB: deleted documents were not included", count)
+	}
+
+	//an explicit deleted condition replaces the default filter
+	if count, _ := Test.Find(bson.M{"deleted": true}).Count(); count != 1 {
+		t.Error("DB: explicit deleted filter was not respected", count)
+	}
+
+	if err := Test.FindId(deleted.Id).Exec(&TestModel{}); err == nil {
+		t.Error("DB: expected not found error for a deleted document")
+	}
+
+	trash := []*TestModel{}
+
+	if err := Test.Find().OnlyDeleted().Exec(&trash); err != nil || len(trash) != 1 || trash[0].Name != "Deleted" {
+		t.Fatal("DB: unexpected deleted documents", trash, err)
+	}
+
+	//deleted relations are only populated for queries including deleted documents
+	kept.Relation1N = relations
+	kept.Save()
+
+	result := &TestModel{}
+
+	if err := Test.FindId(kept.Id).Populate("Relation1N").Exec(result); err != nil {
+		t.Fatal("DB: document could not be populated", err)
+	}
+
+	if populated, _ := result.Relation1N.([]*TestRelationModel); len(populated) != 1 || populated[0].RelationName != "Kept" {
+		t.Error("DB: deleted relation was populated", populated)
+	}
+
+	//saving a populated document keeps the deleted relations
+	kept.Relation11 = relations[1]
+	kept.Save()
+
+	if err := Test.FindId(kept.Id).Populate("Relation11", "Relation1N").Exec(result); err != nil {
+		t.Fatal("DB: document could not be populated", err)
+	}
+
+	if id, ok := result.Relation11.(bson.ObjectId); !ok || id != relations[1] || result.IsDirty() {
+		t.Error("DB: deleted relation was not kept as id", result.Relation11, result.ChangedFields())
+	}
+
+	result.Number = 10
+
+	if err := result.Save(); err != nil {
+		t.Fatal("DB: populated document could not be saved", err)
+	}
+
+	stored := &TestModel{}
+
+	if err := Test.FindId(kept.Id).Exec(stored); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	if ids, _ := stored.Relation1N.([]bson.ObjectId); len(ids) != 2 || ids[1] != relations[1] || stored.Relation11 != relations[1] {
+		t.Error("DB: deleted relations were removed by save", stored.Relation1N, stored.Relation11)
+	}
+
+	if err := Test.FindId(kept.Id).WithDeleted().Populate("Relation1N").Exec(result); err != nil {
+		t.Fatal("DB: document could not be populated", err)
+	}
+
+	if populated, _ := result.Relation1N.([]*TestRelationModel); len(populated) != 2 {
+		t.Error("DB: deleted relation was not populated", populated)
+	}
+
+	if err := trash[0].Restore(); err != nil || trash[0].IsDeleted() || !trash[0].DeletedAt.IsZero() {
+		t.Fatal("DB: document could not be restored", err)
+	}
+
+	if err := Test.FindOne(bson.M{"name": "Deleted"}).Exec(&TestModel{}); err != nil {
+		t.Error("DB: restored document was not found", err)
+	}
+
+	if err := trash[0].HardDelete(); err != nil {
+		t.Fatal("DB: document could not be removed", err)
+	}
+
+	if err := trash[0].HardDelete(); err == nil {
+		t.Error("DB: expected not found error for a removed document")
+	}
+
+	if count, _ := Test.Find().WithDeleted().Count(); count != 2 {
+		t.Error("DB: document was not removed", count)
+	}
+}
+
+func TestPurge(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	for number := 0; number < 3; number++ {
+
+		testModel := saveTestModel(t, db, "Test", number)
+
+		if number > 0 {
+			testModel.Delete()
+		}
+	}
+
+	Test.collection.Insert(context.Background(), bson.M{"_id": bson.NewObjectId(), "name": "Old", "deleted": true, "deletedAt": time.Now().AddDate(0, -1, 0)})
+
+	if removed, err := Test.Purge(bson.M{"deletedAt": bson.M{"$lt": time.Now().AddDate(0, 0, -7)}}); err != nil || removed != 1 {
+		t.Error("DB: expected one purged document", removed, err)
+	}
+
+	if removed, err := Test.Purge(); err != nil || removed != 2 {
+		t.Error("DB: expected two purged documents", removed, err)
+	}
+
+	if count, _ := Test.Find().WithDeleted().Count(); count != 1 {
+		t.Error("DB: not deleted document was purged", count)
+	}
+}
@@ -0,0 +1,132 @@
+package mongodm
+
+import (
+	"reflect"
+)
+
+//tenantView stores the state of a connection which was created with func (*Connection) Tenant
+type tenantView struct {
+	root     *Connection
+	database string
+	models   map[*Model]*Model // models of the root connection mapped to the models of the tenant
+}
+
+/*
+Tenant returns a view of the connection which works on another database. The view shares the registered models,
+the locals and the session pool of the connection, so models have to be registered only once. All models of the view
+(including populated relations and autosaved children) read from and write to the database of the tenant.
+Calling Tenant with the same database name again returns the same view.
+
+For example:
+	User := connection.Tenant("acme").Model("User")
+
+	users := []*models.User{}
+
+	User.Find().Exec(&users) //finds all users of the "acme" database
+*/
+func (self *Connection) Tenant(database string) *Connection {
+
+	root := self
+
+	if self.tenant != nil {
+		root = self.tenant.root
+	}
+
+	root.registryMutex.Lock()
+	defer root.registryMutex.Unlock()
+
+	if root.tenants == nil {
+		root.tenants = make(map[string]*Connection)
+	}
+
+	if tenant, ok := root.tenants[database]; ok {
+		return tenant
+	}
+
+	tenant := &Connection{
+		Config:  root.Config,
+		Session: root.Session,
+		backend: root.backend,
+		tenant: &tenantView{
+			root:     root,
+			database: database,
+			models:   make(map[*Model]*Model),
+		},
+	}
+
+	root.tenants[database] = tenant
+
+	return tenant
+}
+
+//Database returns the database name of a tenant view or an empty string for the default database of the connection.
+func (self *Connection) Database() string {
+
+	if self.tenant != nil {
+		return self.tenant.database
+	}
+
+	return ""
+}
+
+//tenantModel returns the model of the tenant view for a model of the root connection
+func (self *Connection) tenantModel(model *Model) *Model {
+
+	self.registryMutex.Lock()
+	defer self.registryMutex.Unlock()
+
+	if tenantModel, ok := self.tenant.models[model]; ok {
+		return tenantModel
+	}
+
+	tenantModel := &Model{
+		connection: self,
+		database:   self.tenant.database,
+		name:       model.name,
+		config:     model.config,
+		schema:     model.schema,
+	}
+
+	tenantModel.collection = tenantModel.backendCollection(DefaultMode)
+
+	if self.Session != nil {
+		tenantModel.Collection = self.Session.DB(self.tenant.database).C(model.name)
+	}
+
+	self.tenant.models[model] = tenantModel
+
+	return tenantModel
+}
+
+/*
+adopt binds a document of another view of the same connection to this view, so autosaved children are stored
+in the database of the parent document. Documents of other connections are not changed.
+*/
+func (self *Connection) adopt(document *DocumentBase) {
+
+	if document.connection == nil || document.connection == self || document.document == nil {
+		return
+	}
+
+	if document.connection.rootConnection() != self.rootConnection() {
+		return
+	}
+
+	model, err := self.LookupModel(qualifiedTypeName(reflect.TypeOf(document.document).Elem()))
+
+	if err != nil {
+		return
+	}
+
+	document.model = model
+	document.connection = self
+}
+
+func (self *Connection) rootConnection() *Connection {
+
+	if self.tenant != nil {
+		return self.tenant.root
+	}
+
+	return self
+}
@@ -0,0 +1,99 @@
+package mongodm
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestTenantModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name     string      `json:"name" bson:"name"`
+	Relation interface{} `json:"relation" bson:"relation" model:"TestRelationModel" relation:"11" autosave:"true"`
+}
+
+func TestTenantIsolation(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	acme := db.Tenant("acme")
+
+	if acme != db.Tenant("acme") || acme != acme.Tenant("acme") {
+		t.Error("DB: expected the same view for the same tenant")
+	}
+
+	if acme.Database() != "acme" || len(db.Database()) > 0 {
+		t.Error("DB: database of the tenant view is wrong", acme.Database())
+	}
+
+	testModel := &TestModel{}
+
+	acme.Model("testmodel").New(testModel)
+
+	testModel.Name = "Acme"
+	testModel.RequiredField = "Test"
+	testModel.Relation1N = []bson.ObjectId{}
+
+	if err := testModel.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	if count, _ := acme.Model("testmodel").Find().Count(); count != 1 {
+		t.Error("DB: document was not stored in the tenant database", count)
+	}
+
+	if count, _ := db.Model("testmodel").Find().Count(); count != 0 {
+		t.Error("DB: document of the tenant is visible in the default database", count)
+	}
+
+	if count, _ := db.Tenant("other").Model("testmodel").Find().Count(); count != 0 {
+		t.Error("DB: document of the tenant is visible for another tenant", count)
+	}
+}
+
+func TestTenantPopulationAndAutosave(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Tenant("acme").Register(&TestTenantModel{}, "tenantmodels")
+
+	if _, err := db.LookupModel("TestTenantModel"); err != nil {
+		t.Error("DB: registration of a tenant view must be shared with the connection", err)
+	}
+
+	acme := db.Tenant("acme")
+
+	relation := &TestRelationModel{}
+
+	db.Model("TestRelationModel").New(relation) // initialized for the default database
+
+	relation.RelationName = "child"
+
+	tenantModel := &TestTenantModel{}
+
+	acme.Model("TestTenantModel").New(tenantModel)
+
+	tenantModel.Name = "parent"
+	tenantModel.Relation = relation
+
+	if err := tenantModel.Save(); err != nil {
+		t.Fatal("DB: creation error", err)
+	}
+
+	if count, _ := db.Model("TestRelationModel").Find().Count(); count != 0 {
+		t.Error("DB: autosaved child was stored in the default database", count)
+	}
+
+	found := &TestTenantModel{}
+
+	if err := acme.Model("TestTenantModel").FindId(tenantModel.Id).Populate("Relation").Exec(found); err != nil {
+		t.Fatal("DB: population in tenant failed", err)
+	}
+
+	if populated, ok := found.Relation.(*TestRelationModel); !ok || populated.RelationName != "child" {
+		t.Error("DB: relation was not populated from the tenant database", found.Relation)
+	}
+}
@@ -0,0 +1,331 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+Update collects atomic update operators (e.g. $inc or $push) which are applied by the database without loading the
+document first. Start it on a document to update this document (see: func (*Update) Apply) or with NewUpdate for
+func (*Model) UpdateMany. Each method returns the same update reference to enable chains.
+
+The document is not validated, the updatedAt timestamp is set automatically and the version is incremented
+for models with versioning (see: ModelConfig.Versioning).
+
+For example:
+	err := article.Inc("views", 1).AddToSet("tags", "go").Apply()
+*/
+type Update struct {
+	document  *DocumentBase
+	operators bson.M // e.g. {"$inc": {"views": 1}}
+}
+
+//NewUpdate returns an empty update, e.g. for func (*Model) UpdateMany
+func NewUpdate() *Update {
+
+	return &Update{operators: bson.M{}}
+}
+
+//See: https://docs.mongodb.com/manual/reference/operator/update/set/
+func (self *Update) Set(field string, value interface{}) *Update {
+
+	return self.operator("$set", field, value)
+}
+
+//See: https://docs.mongodb.com/manual/reference/operator/update/unset/
+func (self *Update) Unset(field string) *Update {
+
+	return self.operator("$unset", field, "")
+}
+
+//See: https://docs.mongodb.com/manual/reference/operator/update/inc/
+func (self *Update) Inc(field string, value interface{}) *Update {
+
+	return self.operator("$inc", field, value)
+}
+
+//Push appends one or more values to an array, see: https://docs.mongodb.com/manual/reference/operator/update/push/
+func (self *Update) Push(field string, values ...interface{}) *Update {
+
+	return self.operator("$push", field, each(values))
+}
+
+//Pull removes all array elements which equal the value or match the condition, see: https://docs.mongodb.com/manual/reference/operator/update/pull/
+func (self *Update) Pull(field string, condition interface{}) *Update {
+
+	return self.operator("$pull", field, condition)
+}
+
+//AddToSet appends one or more values to an array if they are not contained already, see: https://docs.mongodb.com/manual/reference/operator/update/addToSet/
+func (self *Update) AddToSet(field string, values ...interface{}) *Update {
+
+	return self.operator("$addToSet", field, each(values))
+}
+
+//Min sets the field to the value if it is lower than the stored one, see: https://docs.mongodb.com/manual/reference/operator/update/min/
+func (self *Update) Min(field string, value interface{}) *Update {
+
+	return self.operator("$min", field, value)
+}
+
+//Max sets the field to the value if it is greater than the stored one, see: https://docs.mongodb.com/manual/reference/operator/update/max/
+func (self *Update) Max(field string, value interface{}) *Update {
+
+	return self.operator("$max", field, value)
+}
+
+func (self *Update) operator(operator string, field string, value interface{}) *Update {
+
+	fields, ok := self.operators[operator].(bson.M)
+
+	if !ok {
+		fields = bson.M{}
+		self.operators[operator] = fields
+	}
+
+	fields[field] = value
+
+	return self
+}
+
+//each wraps multiple values for $push and $addToSet with the $each modifier
+func each(values []interface{}) interface{} {
+
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return bson.M{"$each": values}
+}
+
+//build returns the update document with the automatic changes of the model (updatedAt and version)
+func (self *Update) build(model *Model, now time.Time) bson.M {
+
+	update := bson.M{}
+
+	for operator, fields := range self.operators {
+
+		copied := bson.M{}
+
+		for field, value := range fields.(bson.M) {
+			copied[field] = value
+		}
+
+		update[operator] = copied
+	}
+
+	self.automatic(update, "$set", "updatedAt", now)
+
+	if model.config.Versioning {
+		self.automatic(update, "$inc", "version", 1)
+	}
+
+	return update
+}
+
+//automatic adds an operator for a field which is maintained by the ODM, unless the field is updated explicitly
+func (self *Update) automatic(update bson.M, operator string, field string, value interface{}) {
+
+	for _, fields := range update {
+
+		if _, found := fields.(bson.M)[field]; found {
+			return
+		}
+	}
+
+	if _, ok := update[operator]; !ok {
+		update[operator] = bson.M{}
+	}
+
+	update[operator].(bson.M)[field] = value
+}
+
+/*
+Apply runs the update for the document it was started on. Afterwards the document contains the updated values of
+the database, populated relations are reset to their ids. Like Find, a document which was deleted in the meantime
+(see: func (*DocumentBase) Delete) is not updated and a *NotFoundError is returned, unless the document is deleted itself.
+
+For example:
+	err := user.Inc("logins", 1).Set("lastLogin", time.Now()).Apply()
+*/
+func (self *Update) Apply() error {
+
+	return self.ApplyContext(context.Background())
+}
+
+//ApplyContext works like Apply but is canceled as soon as the given context is done.
+func (self *Update) ApplyContext(ctx context.Context) error {
+
+	document := self.document
+
+	if document == nil {
+		return &SchemaError{&QueryError{"DB: The update was not started on a document, use Model.UpdateMany instead"}}
+	}
+
+	if document.document == nil || document.model == nil || document.connection == nil {
+		panic("You have to initialize your document with *Model.New(document IDocumentBase) before using Apply()!")
+	}
+
+	if !document.Id.Valid() {
+		return errors.New("Invalid object id")
+	}
+
+	if len(self.operators) == 0 {
+		return nil
+	}
+
+	ctx, done, err := document.connection.begin(ctx, fmt.Sprintf("Apply on collection '%v'", document.model.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	filter := bson.M{"_id": document.Id}
+
+	//like Find, a document which was deleted in the meantime is not updated
+	if !document.Deleted {
+		filter["deleted"] = bson.M{"$ne": true}
+	}
+
+	raw, found, err := document.model.collection.FindAndUpdate(ctx, filter, self.build(document.model, time.Now()))
+
+	if err != nil {
+		return err
+	} else if !found {
+		return &NotFoundError{&QueryError{fmt.Sprintf("No record found")}}
+	}
+
+	return document.refresh(raw)
+}
+
+//refresh replaces the content of the document with a stored version of it (like a new Exec)
+func (self *DocumentBase) refresh(raw bson.Raw) error {
+
+	raws, err := self.model.upgrade([]bson.Raw{raw})
+
+	if err != nil {
+		return err
+	}
+
+	document := reflect.ValueOf(self.document)
+	fresh := reflect.New(document.Elem().Type())
+
+	if err := raws[0].Unmarshal(fresh.Interface()); err != nil {
+		return err
+	}
+
+	//the references of the document (e.g. the connection of a tenant) are kept
+	documentReference, model, connection := self.document, self.model, self.connection
+
+	document.Elem().Set(fresh.Elem())
+
+	self.document, self.model, self.connection = documentReference, model, connection
+
+	query := &Query{model: model, connection: connection}
+
+	if err := query.loaded(document, raws[0]); err != nil {
+		return err
+	}
+
+	query.initWithObjectId(document)
+
+	self.track()
+
+	return nil
+}
+
+/*
+UpdateMany applies the update to all documents which match the filter and returns their number. Like Find,
+soft deleted documents are skipped unless the filter checks the "deleted" key itself.
+
+For example:
+	n, err := User.UpdateMany(bson.M{"newsletter": true}, mongodm.NewUpdate().Inc("mailsSent", 1))
+*/
+func (self *Model) UpdateMany(filter interface{}, update *Update) (int, error) {
+
+	return self.UpdateManyContext(context.Background(), filter, update)
+}
+
+//UpdateManyContext works like UpdateMany but is canceled as soon as the given context is done.
+func (self *Model) UpdateManyContext(ctx context.Context, filter interface{}, update *Update) (int, error) {
+
+	if update == nil || len(update.operators) == 0 {
+		return 0, nil
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("UpdateMany on collection '%v'", self.name))
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer done()
+
+	return self.collection.UpdateAll(ctx, self.Find(filter).filter(), update.build(self, time.Now()))
+}
+
+//update starts an update of the document (see: type Update)
+func (self *DocumentBase) update() *Update {
+
+	return &Update{document: self, operators: bson.M{}}
+}
+
+//Set starts an update of the document, see: func (*Update) Set
+func (self *DocumentBase) Set(field string, value interface{}) *Update {
+
+	return self.update().Set(field, value)
+}
+
+//Unset starts an update of the document, see: func (*Update) Unset
+func (self *DocumentBase) Unset(field string) *Update {
+
+	return self.update().Unset(field)
+}
+
+//Inc starts an update of the document, see: func (*Update) Inc
+func (self *DocumentBase) Inc(field string, value interface{}) *Update {
+
+	return self.update().Inc(field, value)
+}
+
+//Push starts an update of the document, see: func (*Update) Push
+func (self *DocumentBase) Push(field string, values ...interface{}) *Update {
+
+	return self.update().Push(field, values...)
+}
+
+//Pull starts an update of the document, see: func (*Update) Pull
+func (self *DocumentBase) Pull(field string, condition interface{}) *Update {
+
+	return self.update().Pull(field, condition)
+}
+
+//AddToSet starts an update of the document, see: func (*Update) AddToSet
+func (self *DocumentBase) AddToSet(field string, values ...interface{}) *Update {
+
+	return self.update().AddToSet(field, values...)
+}
+
+//Min starts an update of the document, see: func (*Update) Min
+func (self *DocumentBase) Min(field string, value interface{}) *Update {
+
+	return self.update().Min(field, value)
+}
+
+//Max starts an update of the document, see: func (*Update) Max
+func (self *DocumentBase) Max(field string, value interface{}) *Update {
+
+	return self.update().Max(field, value)
+}
@@ -0,0 +1,145 @@
+package mongodm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestUpdateModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name  string   `json:"name" bson:"name"`
+	Views int      `json:"views" bson:"views"`
+	Best  int      `json:"best" bson:"best"`
+	Tags  []string `json:"tags" bson:"tags"`
+}
+
+func TestUpdateApply(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestUpdateModel{}, "updated", &ModelConfig{Versioning: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestUpdateModel")
+	document := &TestUpdateModel{}
+
+	model.New(document)
+
+	document.Name = "Article"
+	document.Best = 5
+	document.Tags = []string{"go"}
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	saved := document.UpdatedAt
+
+	time.Sleep(2 * time.Millisecond)
+
+	if err := document.Inc("views", 2).Push("tags", "mongo", "odm").AddToSet("tags", "go").Max("best", 3).Apply(); err != nil {
+		t.Fatal("DB: update could not be applied", err)
+	}
+
+	if document.Views != 2 || document.Best != 5 || !reflect.DeepEqual(document.Tags, []string{"go", "mongo", "odm"}) {
+		t.Error("DB: document was not refreshed", document.Views, document.Best, document.Tags)
+	}
+
+	if !document.UpdatedAt.After(saved) || document.Version != 2 || document.IsDirty() {
+		t.Error("DB: automatic fields were not updated", document.UpdatedAt, document.Version, document.ChangedFields())
+	}
+
+	//the refreshed document can be saved without conflict
+	document.Name = "Renamed"
+
+	if err := document.Save(); err != nil {
+		t.Error("DB: refreshed document could not be saved", err)
+	}
+
+	if err := document.Pull("tags", "mongo").Min("best", 1).Set("views", 10).Unset("name").Apply(); err != nil {
+		t.Fatal("DB: update could not be applied", err)
+	}
+
+	if document.Name != "" || document.Views != 10 || document.Best != 1 || !reflect.DeepEqual(document.Tags, []string{"go", "odm"}) {
+		t.Error("DB: document was not refreshed", document)
+	}
+
+	if err := NewUpdate().Inc("views", 1).Apply(); err == nil {
+		t.Error("DB: expected error for an update without document")
+	}
+
+	//another request deletes the document
+	deleted := &TestUpdateModel{}
+
+	if err := model.FindId(document.Id).Exec(deleted); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	if err := deleted.Delete(); err != nil {
+		t.Fatal("DB: document could not be deleted", err)
+	}
+
+	if err := document.Inc("views", 1).Apply(); err == nil {
+		t.Error("DB: expected not found error for a deleted document")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("DB: expected not found error for a deleted document", err)
+	}
+
+	if err := deleted.Inc("views", 1).Apply(); err != nil || deleted.Views != 11 {
+		t.Error("DB: deleted document could not be updated", deleted.Views, err)
+	}
+
+	model.collection.Remove(context.Background(), bson.M{"_id": document.Id})
+
+	if err := document.Inc("views", 1).Apply(); err == nil {
+		t.Error("DB: expected not found error for a removed document")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("DB: expected not found error for a removed document", err)
+	}
+}
+
+func TestUpdateMany(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	Test := db.Model("testmodel")
+
+	for number := 0; number < 4; number++ {
+
+		testModel := saveTestModel(t, db, "Test", number)
+
+		if number == 3 {
+			testModel.Delete()
+		}
+	}
+
+	//deleted documents are skipped
+	if n, err := Test.UpdateMany(bson.M{"number": bson.M{"$gte": 1}}, NewUpdate().Inc("number", 10)); err != nil || n != 2 {
+		t.Fatal("DB: unexpected number of updated documents", n, err)
+	}
+
+	if count, _ := Test.Find(bson.M{"number": bson.M{"$gte": 10}}).Count(); count != 2 {
+		t.Error("DB: documents were not updated", count)
+	}
+
+	if n, _ := Test.UpdateMany(nil, NewUpdate().Set("name", "All")); n != 3 {
+		t.Error("DB: unexpected number of updated documents", n)
+	}
+
+	if n, _ := Test.UpdateMany(bson.M{"deleted": true}, NewUpdate().Set("name", "Deleted")); n != 1 {
+		t.Error("DB: deleted document was not updated", n)
+	}
+
+	//the memory driver rejects invalid updates like the server
+	if _, err := Test.UpdateMany(nil, NewUpdate().Inc("number", "x")); err == nil {
+		t.Error("DB: expected error for a non numeric increment")
+	}
+}
@@ -0,0 +1,89 @@
+package mongodm
+
+import (
+	"fmt"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+Upgrade converts a stored document from one schema version to the next one (see: ModelConfig.Upgrades).
+The document is passed in its stored form before it is decoded into the struct, so renamed or restructured keys
+can still be read.
+
+For example (the name was split into firstname and lastname):
+	func(document bson.M) error {
+
+		name, _ := document["name"].(string)
+		parts := strings.SplitN(name, " ", 2)
+
+		document["firstname"] = parts[0]
+
+		if len(parts) > 1 {
+			document["lastname"] = parts[1]
+		}
+
+		delete(document, "name")
+
+		return nil
+	}
+*/
+type Upgrade func(document bson.M) error
+
+/*
+SchemaVersion returns the current schema version of the model documents, which is the number of upgrades
+(see: ModelConfig.Upgrades). Documents without version have version 0.
+*/
+func (self *Model) SchemaVersion() int {
+
+	return len(self.config.Upgrades)
+}
+
+/*
+upgrade applies the upgrades of the model to all documents with an older schema version. The upgraded documents are
+not written back, this happens on the next save of each document. Until then the document keeps its stored schema
+version, so Save knows that it has to replace the whole document instead of writing the changes only.
+Documents of a newer version are left untouched.
+*/
+func (self *Model) upgrade(raws []bson.Raw) ([]bson.Raw, error) {
+
+	current := self.SchemaVersion()
+
+	if current == 0 {
+		return raws, nil
+	}
+
+	for index, raw := range raws {
+
+		document := bson.M{}
+
+		if err := raw.Unmarshal(&document); err != nil {
+			return nil, err
+		}
+
+		//other drivers and the mongo shell store the version as int64 or double
+		stored, _ := toFloat(document["schemaVersion"])
+		version := int(stored)
+
+		if version >= current {
+			continue
+		}
+
+		for ; version < current; version++ {
+
+			if err := self.config.Upgrades[version](document); err != nil {
+				return nil, fmt.Errorf("DB: Upgrade of document %v in collection '%v' from version %v failed: %w", document["_id"], self.name, version, err)
+			}
+		}
+
+		upgraded, err := rawDocument(document)
+
+		if err != nil {
+			return nil, err
+		}
+
+		raws[index] = upgraded
+	}
+
+	return raws, nil
+}
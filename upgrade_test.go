@@ -0,0 +1,248 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestUpgradeModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	FirstName string `json:"firstname" bson:"firstname"`
+	LastName  string `json:"lastname" bson:"lastname"`
+	Age       int    `json:"age" bson:"age"`
+}
+
+func TestUpgradeOnLoad(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	err := db.Register(&TestUpgradeModel{}, "upgraded", &ModelConfig{Upgrades: []Upgrade{
+
+		//version 0 stored the full name
+		func(document bson.M) error {
+
+			parts := strings.SplitN(document["name"].(string), " ", 2)
+
+			document["firstname"], document["lastname"] = parts[0], parts[1]
+			delete(document, "name")
+
+			return nil
+		},
+
+		//version 1 stored the age as string
+		func(document bson.M) error {
+
+			if document["age"] == "unknown" {
+				return errors.New("unknown age")
+			}
+
+			document["age"] = len(document["age"].(string))
+
+			return nil
+		},
+	}})
+
+	if err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestUpgradeModel")
+	ctx := context.Background()
+
+	if model.SchemaVersion() != 2 {
+		t.Error("DB: unexpected schema version", model.SchemaVersion())
+	}
+
+	model.collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "name": "Max Mustermann", "age": "xxx"})
+	model.collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "firstname": "Erika", "lastname": "Mustermann", "age": "xx", "schemaVersion": 1})
+
+	documents := []*TestUpgradeModel{}
+
+	//filters and sorting work on the stored form, so they must consider older versions, too
+	if err := model.Find().Sort("_id").Exec(&documents); err != nil {
+		t.Fatal("DB: documents could not be loaded", err)
+	}
+
+	if len(documents) != 2 || documents[0].LastName != "Mustermann" || documents[0].Age != 3 || documents[1].FirstName != "Erika" || documents[1].Age != 2 {
+		t.Fatalf("DB: documents were not upgraded %+v %+v", documents[0], documents[1])
+	}
+
+	//the upgraded form is stored on save
+	if err := documents[0].Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	if count, _ := model.collection.Count(ctx, bson.M{"schemaVersion": 2, "firstname": "Max", "age": 3, "name": bson.M{"$exists": false}}); count != 1 {
+		t.Error("DB: upgraded document was not stored")
+	}
+
+	//new documents get the current version
+	document := &TestUpgradeModel{}
+
+	model.New(document)
+	document.FirstName = "Hans"
+
+	if err := document.Save(); err != nil || document.SchemaVersion != 2 {
+		t.Error("DB: new document has the wrong schema version", document.SchemaVersion, err)
+	}
+
+	model.collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "firstname": "Otto", "age": "unknown", "schemaVersion": 1})
+
+	if err := model.FindOne(bson.M{"firstname": "Otto"}).Exec(document); err == nil || !strings.Contains(err.Error(), "unknown age") {
+		t.Error("DB: expected upgrade error", err)
+	}
+}
+
+func TestUpgradeNumericVersion(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	err := db.Register(&TestUpgradeModel{}, "upgraded", &ModelConfig{Upgrades: []Upgrade{
+		func(document bson.M) error {
+			return errors.New("upgrade applied again")
+		},
+	}})
+
+	if err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestUpgradeModel")
+	ctx := context.Background()
+
+	//versions written by the mongo shell and other drivers
+	model.collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "firstname": "Max", "schemaVersion": float64(1)})
+	model.collection.Insert(ctx, bson.M{"_id": bson.NewObjectId(), "firstname": "Erika", "schemaVersion": int64(1)})
+
+	documents := []*TestUpgradeModel{}
+
+	if err := model.Find().Exec(&documents); err != nil || len(documents) != 2 {
+		t.Fatal("DB: upgraded documents were upgraded again", err)
+	}
+
+	if documents[0].SchemaVersion != 1 || documents[1].SchemaVersion != 1 {
+		t.Error("DB: unexpected schema versions", documents[0].SchemaVersion, documents[1].SchemaVersion)
+	}
+}
+
+func TestUpgradeRetry(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	err := db.Register(&TestUpgradeModel{}, "upgraded", &ModelConfig{Upgrades: []Upgrade{
+		func(document bson.M) error {
+
+			parts := strings.SplitN(document["name"].(string), " ", 2)
+
+			document["firstname"], document["lastname"] = parts[0], parts[1]
+			delete(document, "name")
+
+			return nil
+		},
+	}})
+
+	if err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestUpgradeModel")
+
+	if err := model.CreateIndex(Index{Key: []string{"firstname"}, Unique: true}); err != nil {
+		t.Fatal("DB: index creation failed", err)
+	}
+
+	id := bson.NewObjectId()
+
+	model.collection.Insert(context.Background(), bson.M{"_id": id, "name": "Max Mustermann"})
+	model.collection.Insert(context.Background(), bson.M{"_id": bson.NewObjectId(), "firstname": "Erika", "schemaVersion": 1})
+
+	document := &TestUpgradeModel{}
+
+	if err := model.FindId(id).Exec(document); err != nil {
+		t.Fatal("DB: document could not be loaded", err)
+	}
+
+	document.FirstName = "Erika"
+
+	if err := document.Save(); err == nil {
+		t.Fatal("DB: expected duplicate error")
+	}
+
+	if document.SchemaVersion != 0 {
+		t.Error("DB: schema version was not reset after the failed save", document.SchemaVersion)
+	}
+
+	//a new document is inserted with the current schema version only if the insert succeeds
+	created := &TestUpgradeModel{}
+
+	model.New(created)
+	created.FirstName = "Erika"
+
+	if err := created.Save(); err == nil || created.SchemaVersion != 0 {
+		t.Error("DB: schema version was not reset after the failed insert", created.SchemaVersion, err)
+	}
+
+	//the retry still replaces the whole document, so the outdated key is removed
+	document.FirstName = "Moritz"
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	if count, _ := model.Find(bson.M{"name": bson.M{"$exists": true}}).Count(); count != 0 {
+		t.Error("DB: outdated key was not removed")
+	}
+}
+
+func TestUpgradeProjection(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	err := db.Register(&TestUpgradeModel{}, "upgraded", &ModelConfig{Upgrades: []Upgrade{
+		func(document bson.M) error {
+			return errors.New("upgrade applied to a current document")
+		},
+	}})
+
+	if err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestUpgradeModel")
+	document := &TestUpgradeModel{}
+
+	model.New(document)
+
+	document.FirstName = "Max"
+	document.LastName = "Mustermann"
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	//the schema version is loaded without being selected
+	partial := &TestUpgradeModel{}
+
+	if err := model.FindId(document.Id).Select(bson.M{"firstname": 1}).Exec(partial); err != nil || partial.SchemaVersion != 1 {
+		t.Fatal("DB: schema version of partial document was not loaded", partial.SchemaVersion, err)
+	}
+
+	partial.FirstName = "Moritz"
+
+	if err := partial.Save(); err != nil {
+		t.Fatal("DB: partial document could not be saved", err)
+	}
+
+	if count, _ := model.Find(bson.M{"firstname": "Moritz", "lastname": "Mustermann"}).Count(); count != 1 {
+		t.Error("DB: partial document was not saved")
+	}
+}
@@ -0,0 +1,293 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//ValidationLevel decides which writes are checked by the validator of a collection (see: ValidatorConfig)
+type ValidationLevel string
+
+//ValidationAction decides what happens with writes which fail the validation (see: ValidatorConfig)
+type ValidationAction string
+
+const (
+	ValidationLevelOff      ValidationLevel = "off"      // no validation
+	ValidationLevelStrict   ValidationLevel = "strict"   // validate all inserts and updates (default)
+	ValidationLevelModerate ValidationLevel = "moderate" // don't validate updates of documents which were invalid before
+
+	ValidationActionError ValidationAction = "error" // reject invalid documents with a *ValidationError (default)
+	ValidationActionWarn  ValidationAction = "warn"  // accept invalid documents and write a warning to the server log
+)
+
+/*
+ValidatorConfig contains the settings of a collection validator (see: func (*Model) ApplyValidator).
+
+For example:
+	connection.Register(&User{}, "users", &mongodm.ModelConfig{
+		Validator: &mongodm.ValidatorConfig{Level: mongodm.ValidationLevelModerate},
+	})
+*/
+type ValidatorConfig struct {
+	Level  ValidationLevel  // default is ValidationLevelStrict
+	Action ValidationAction // default is ValidationActionError
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIdType = reflect.TypeOf(bson.ObjectId(""))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+/*
+JSONSchema returns a $jsonSchema document for the document type of the model. It contains the bson types of all fields,
+the required fields, the string lengths (minLen, maxLen), the patterns (validation) and the id types of relations.
+Like func (*DocumentBase) DefaultValidate, the string rules are only checked for non empty strings unless the field is required.
+The server can only check that a required field is present and not null, so required strings and lists also must not be empty,
+but e.g. a required zero number is accepted.
+
+For example:
+	fmt.Println(connection.Model("User").JSONSchema())
+*/
+func (self *Model) JSONSchema() bson.M {
+
+	return self.schema.jsonSchema()
+}
+
+/*
+ApplyValidator sets the $jsonSchema of the model (see: func (*Model) JSONSchema) as validator of the collection, so writes
+of other applications (or the mongo shell) are checked against the tags, too. A collection which does not exist yet
+is created. Pass nil to use the default config (ValidationLevelStrict and ValidationActionError). Invalid documents are
+rejected with a *ValidationError.
+
+For example:
+	err := connection.Model("User").ApplyValidator(&mongodm.ValidatorConfig{Action: mongodm.ValidationActionWarn})
+*/
+func (self *Model) ApplyValidator(config *ValidatorConfig) error {
+
+	return self.ApplyValidatorContext(context.Background(), config)
+}
+
+//ApplyValidatorContext works like ApplyValidator but is canceled as soon as the given context is done.
+func (self *Model) ApplyValidatorContext(ctx context.Context, config *ValidatorConfig) error {
+
+	ctx, done, err := self.connection.begin(ctx, fmt.Sprintf("ApplyValidator on collection '%v'", self.name))
+
+	if err != nil {
+		return err
+	}
+
+	defer done()
+
+	level := ValidationLevelStrict
+	action := ValidationActionError
+
+	if config != nil && len(config.Level) > 0 {
+		level = config.Level
+	}
+
+	if config != nil && len(config.Action) > 0 {
+		action = config.Action
+	}
+
+	return self.backendCollection(DefaultMode).SetValidator(ctx, bson.M{"$jsonSchema": self.JSONSchema()}, level, action)
+}
+
+//jsonSchema returns the $jsonSchema document of the schema
+func (self *schema) jsonSchema() bson.M {
+
+	properties := bson.M{}
+	required := []string{}
+
+	self.appendJSONSchema(properties, &required, map[reflect.Type]bool{})
+
+	jsonSchema := bson.M{"bsonType": "object", "properties": properties}
+
+	if len(required) > 0 {
+		jsonSchema["required"] = required
+	}
+
+	return jsonSchema
+}
+
+//appendJSONSchema adds the properties of all fields, fields of inline structs (e.g. DocumentBase) are added to the same document
+func (self *schema) appendJSONSchema(properties bson.M, required *[]string, visited map[reflect.Type]bool) {
+
+	visited[self.documentType] = true
+
+	for _, field := range self.fields {
+
+		structField := self.documentType.Field(field.index)
+
+		// unexported fields are not stored
+		if len(structField.PkgPath) > 0 || field.bsonName == "-" {
+			continue
+		}
+
+		if field.inline {
+
+			fieldType := structField.Type
+
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			if fieldType.Kind() != reflect.Struct || visited[fieldType] {
+				continue
+			}
+
+			if inlineSchema, err := schemaOf(fieldType); err == nil {
+				inlineSchema.appendJSONSchema(properties, required, visited)
+			}
+
+			continue
+		}
+
+		properties[field.bsonName] = field.jsonSchema(structField.Type)
+
+		if field.required {
+			*required = append(*required, field.bsonName)
+		}
+	}
+}
+
+//jsonSchema returns the $jsonSchema of a single field
+func (self *schemaField) jsonSchema(fieldType reflect.Type) bson.M {
+
+	var property bson.M
+	var nullable bool
+
+	if self.isRelation() {
+
+		// relations are stored as ids (see: func (*DocumentBase) Save)
+		if self.relationType() == REL_1N {
+			property = bson.M{"bsonType": "array", "items": bson.M{"bsonType": "objectId"}}
+		} else {
+			property = bson.M{"bsonType": "objectId"}
+		}
+
+		nullable = true
+
+	} else {
+		property, nullable = typeJSONSchema(fieldType)
+	}
+
+	bsonType, typed := property["bsonType"]
+
+	if !typed {
+		return property
+	}
+
+	if nullable && !self.required {
+		property["bsonType"] = append(bsonTypes(bsonType), "null")
+	}
+
+	if bsonType == "array" && self.required {
+		property["minItems"] = 1
+	}
+
+	if bsonType != "string" {
+		return property
+	}
+
+	constraints := bson.M{}
+
+	if self.minLen > 0 {
+		constraints["minLength"] = self.minLen
+	}
+
+	if self.maxLen > 0 {
+		constraints["maxLength"] = self.maxLen
+	}
+
+	if self.regex != nil {
+		constraints["pattern"] = self.regex.String()
+	} else if self.validation == "email" {
+		constraints["pattern"] = emailRegex.String()
+	}
+
+	if self.required {
+
+		if self.minLen == 0 {
+			constraints["minLength"] = 1
+		}
+
+		for key, value := range constraints {
+			property[key] = value
+		}
+
+	} else if len(constraints) > 0 {
+
+		// like DefaultValidate: the rules only apply to non empty strings
+		property["anyOf"] = []bson.M{{"maxLength": 0}, constraints}
+	}
+
+	return property
+}
+
+//typeJSONSchema returns the $jsonSchema of a go type and reports if nil values of the type are stored as null
+func typeJSONSchema(fieldType reflect.Type) (bson.M, bool) {
+
+	switch fieldType {
+	case timeType:
+		return bson.M{"bsonType": "date"}, false
+	case objectIdType:
+		return bson.M{"bsonType": "objectId"}, false
+	case bytesType:
+		return bson.M{"bsonType": "binData"}, true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Ptr:
+
+		property, _ := typeJSONSchema(fieldType.Elem())
+
+		return property, true
+
+	case reflect.String:
+		return bson.M{"bsonType": "string"}, false
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}, false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		// mgo stores small values of int as int32
+		return bson.M{"bsonType": []string{"int", "long"}}, false
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "double"}, false
+	case reflect.Slice, reflect.Array:
+
+		property := bson.M{"bsonType": "array"}
+
+		if items, nullable := typeJSONSchema(fieldType.Elem()); len(items) > 0 {
+
+			if nullable {
+				items["bsonType"] = append(bsonTypes(items["bsonType"]), "null")
+			}
+
+			property["items"] = items
+		}
+
+		return property, fieldType.Kind() == reflect.Slice
+
+	case reflect.Map:
+		return bson.M{"bsonType": "object"}, true
+	case reflect.Struct:
+		return bson.M{"bsonType": "object"}, false
+	}
+
+	// interfaces can store any type
+	return bson.M{}, false
+}
+
+//bsonTypes returns the bsonType keyword as list
+func bsonTypes(bsonType interface{}) []string {
+
+	if types, ok := bsonType.([]string); ok {
+		return append([]string{}, types...)
+	}
+
+	return []string{fmt.Sprint(bsonType)}
+}
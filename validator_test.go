@@ -0,0 +1,117 @@
+package mongodm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestValidatorModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name     string        `json:"name" bson:"name" required:"true" minLen:"2" maxLen:"20"`
+	Email    string        `json:"email" bson:"email" validation:"email"`
+	Code     string        `json:"code" bson:"code" validation:"/^[A-Z]+$/"`
+	Age      int           `json:"age" bson:"age"`
+	Tags     []string      `json:"tags" bson:"tags"`
+	Nickname *string       `json:"nickname" bson:"nickname,omitempty"`
+	Owner    interface{}   `json:"owner" bson:"owner" model:"TestValidatorModel"`
+	Friends  interface{}   `json:"friends" bson:"friends" model:"TestValidatorModel" relation:"1n"`
+	Hidden   string        `json:"-" bson:"-"`
+	Ref      bson.ObjectId `json:"ref" bson:"ref,omitempty"`
+}
+
+func TestJSONSchema(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	db.Register(&TestValidatorModel{}, "validated")
+
+	jsonSchema := db.Model("TestValidatorModel").JSONSchema()
+	properties := jsonSchema["properties"].(bson.M)
+
+	expected := map[string]bson.M{
+		"_id":       {"bsonType": "objectId"},
+		"createdAt": {"bsonType": "date"},
+		"deleted":   {"bsonType": "bool"},
+		"name":      {"bsonType": "string", "minLength": 2, "maxLength": 20},
+		"email":     {"bsonType": "string", "anyOf": []bson.M{{"maxLength": 0}, {"pattern": emailRegex.String()}}},
+		"code":      {"bsonType": "string", "anyOf": []bson.M{{"maxLength": 0}, {"pattern": "^[A-Z]+$"}}},
+		"age":       {"bsonType": []string{"int", "long"}},
+		"tags":      {"bsonType": []string{"array", "null"}, "items": bson.M{"bsonType": "string"}},
+		"nickname":  {"bsonType": []string{"string", "null"}},
+		"owner":     {"bsonType": []string{"objectId", "null"}},
+		"friends":   {"bsonType": []string{"array", "null"}, "items": bson.M{"bsonType": "objectId"}},
+	}
+
+	for name, property := range expected {
+
+		if !reflect.DeepEqual(properties[name], property) {
+			t.Errorf("DB: unexpected schema of field '%v': %v", name, properties[name])
+		}
+	}
+
+	if _, ok := properties["-"]; ok {
+		t.Error("DB: ignored field must not be part of the schema")
+	}
+
+	if !reflect.DeepEqual(jsonSchema["required"], []string{"name"}) {
+		t.Errorf("DB: unexpected required fields %v", jsonSchema["required"])
+	}
+}
+
+func TestApplyValidator(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestValidatorModel{}, "validated", &ModelConfig{Validator: &ValidatorConfig{}}); err != nil {
+		t.Fatal("DB: validator could not be applied", err)
+	}
+
+	model := db.Model("TestValidatorModel")
+	ctx := context.Background()
+
+	document := &TestValidatorModel{}
+	model.New(document)
+	document.Name = "Max"
+	document.Email = "max@example.com"
+	document.Friends = []bson.ObjectId{}
+
+	if err := document.Save(); err != nil {
+		t.Fatal("DB: valid document was rejected", err)
+	}
+
+	//writes which bypass DefaultValidate are checked by the collection
+	invalid := []bson.M{
+		{"name": "M"},
+		{"email": "max@example.com"},
+		{"name": "Max", "code": "abc"},
+		{"name": "Max", "owner": "not an id"},
+		{"name": "Max", "age": "42"},
+	}
+
+	for _, raw := range invalid {
+
+		if err := model.collection.Insert(ctx, raw); err == nil {
+			t.Errorf("DB: invalid document %v was accepted", raw)
+		} else if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("DB: expected validation error for %v, got %v", raw, err)
+		}
+	}
+
+	if err := model.collection.Insert(ctx, bson.M{"name": "Max", "code": "", "friends": []bson.ObjectId{bson.NewObjectId()}}); err != nil {
+		t.Error("DB: valid document was rejected", err)
+	}
+
+	if err := model.ApplyValidator(&ValidatorConfig{Action: ValidationActionWarn}); err != nil {
+		t.Fatal("DB: validator could not be changed", err)
+	}
+
+	if err := model.collection.Insert(ctx, bson.M{"name": "M"}); err != nil {
+		t.Error("DB: warn action must accept invalid documents", err)
+	}
+}
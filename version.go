@@ -0,0 +1,92 @@
+package mongodm
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+writeVersion stores the already incremented version of the document only if the stored version still equals the
+loaded one, so the check and the increment are one atomic operation (see: ModelConfig.Versioning). Documents which
+were stored without versioning have version 0. A document with version 0 which does not exist yet (e.g. the id was
+set manually) is inserted.
+*/
+func (self *DocumentBase) writeVersion(ctx context.Context, raw bson.Raw, replace bool) error {
+
+	if written, err := self.write(ctx, self.versionFilter(), raw, replace); err != nil || written {
+		return err
+	}
+
+	return self.unmatched(ctx, raw)
+}
+
+//versionFilter matches the document only with the version it was loaded with (before it was incremented for the write)
+func (self *DocumentBase) versionFilter() bson.M {
+
+	version := self.Version - 1
+	filter := bson.M{"_id": self.Id, "version": version}
+
+	//version 0 is never stored (omitempty)
+	if version == 0 {
+		filter["version"] = bson.M{"$exists": false}
+	}
+
+	return filter
+}
+
+/*
+unmatched handles a write of an existing document whose filter matched nothing. Without versioning the document is
+stored completely, unless only a part of it was loaded. With versioning the document was changed, removed or was never
+stored.
+*/
+func (self *DocumentBase) unmatched(ctx context.Context, raw bson.Raw) error {
+
+	if !self.model.config.Versioning && self.projection == nil {
+		return self.model.collection.UpsertId(ctx, self.Id, raw)
+	} else if !self.model.config.Versioning {
+		return self.removedError()
+	}
+
+	version := self.Version - 1
+	current, found, err := self.storedVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !found && version == 0 && self.projection == nil {
+		return self.model.collection.Insert(ctx, raw)
+	} else if !found {
+		return self.removedError()
+	}
+
+	return &ConflictError{&QueryError{fmt.Sprintf("DB: Document %v in collection '%v' was changed in the meantime (version %v, stored version %v)", self.Id.Hex(), self.model.name, version, current)}, self.Id, version, current}
+}
+
+//removedError returns the error for a document which was removed since it was loaded
+func (self *DocumentBase) removedError() error {
+
+	return &NotFoundError{&QueryError{fmt.Sprintf("DB: Document %v in collection '%v' was removed in the meantime", self.Id.Hex(), self.model.name)}}
+}
+
+//storedVersion returns the version of the document in the database and whether it exists
+func (self *DocumentBase) storedVersion(ctx context.Context) (int64, bool, error) {
+
+	raws, err := self.model.collection.Find(ctx, bson.M{"_id": self.Id}, &FindOptions{Selector: bson.M{"version": 1}, Limit: 1})
+
+	if err != nil || len(raws) == 0 {
+		return 0, false, err
+	}
+
+	stored := struct {
+		Version int64 `bson:"version"`
+	}{}
+
+	if err := raws[0].Unmarshal(&stored); err != nil {
+		return 0, false, err
+	}
+
+	return stored.Version, true, nil
+}
@@ -0,0 +1,145 @@
+package mongodm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TestVersionModel struct {
+	DocumentBase `json:",inline" bson:",inline"`
+
+	Name string `json:"name" bson:"name"`
+}
+
+func TestVersioning(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestVersionModel{}, "versioned", &ModelConfig{Versioning: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestVersionModel")
+	document := &TestVersionModel{}
+
+	model.New(document)
+	document.Name = "Max"
+
+	if err := document.Save(); err != nil || document.Version != 1 {
+		t.Fatal("DB: document could not be created", document.Version, err)
+	}
+
+	//two requests load the same document
+	first, second := &TestVersionModel{}, &TestVersionModel{}
+
+	model.FindId(document.Id).Exec(first)
+	model.FindId(document.Id).Exec(second)
+
+	first.Name = "Moritz"
+
+	if err := first.Save(); err != nil || first.Version != 2 {
+		t.Fatal("DB: document could not be saved", first.Version, err)
+	}
+
+	second.Name = "Erika"
+
+	var conflictError *ConflictError
+
+	if err := second.Save(); !errors.As(err, &conflictError) || conflictError.Version != 1 || conflictError.Current != 2 || second.Version != 1 {
+		t.Fatal("DB: expected conflict error", err)
+	}
+
+	if model.FindId(document.Id).Exec(second); second.Name != "Moritz" {
+		t.Error("DB: stale save overwrote the document", second.Name)
+	}
+
+	second.Name = "Erika"
+
+	if err := second.Save(); err != nil || second.Version != 3 {
+		t.Error("DB: reloaded document could not be saved", second.Version, err)
+	}
+
+	//documents which were stored before versioning was enabled have version 0
+	ctx := context.Background()
+	legacyId := bson.NewObjectId()
+
+	model.collection.Insert(ctx, bson.M{"_id": legacyId, "name": "Legacy"})
+
+	legacy := &TestVersionModel{}
+
+	if err := model.FindId(legacyId).Exec(legacy); err != nil {
+		t.Fatal("DB: legacy document could not be loaded", err)
+	}
+
+	if err := legacy.Save(); err != nil || legacy.Version != 1 {
+		t.Error("DB: legacy document could not be saved", legacy.Version, err)
+	}
+
+	model.collection.Remove(ctx, bson.M{"_id": legacyId})
+
+	if err := legacy.Save(); err == nil {
+		t.Error("DB: expected not found error for a removed document")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("DB: expected not found error for a removed document", err)
+	}
+
+	//a new document with a given id is inserted
+	manual := &TestVersionModel{}
+
+	model.New(manual)
+	manual.Id = bson.NewObjectId()
+
+	if err := manual.Save(); err != nil || manual.Version != 1 {
+		t.Error("DB: document with given id could not be created", manual.Version, err)
+	}
+
+	if count, _ := model.Find(bson.M{"version": 1}).Count(); count != 1 {
+		t.Error("DB: document with given id was not stored", count)
+	}
+}
+
+func TestVersioningFailedInsert(t *testing.T) {
+
+	db := memoryConnection(t)
+	defer db.Close()
+
+	if err := db.Register(&TestVersionModel{}, "versioned", &ModelConfig{Versioning: true}); err != nil {
+		t.Fatal("DB: model could not be registered", err)
+	}
+
+	model := db.Model("TestVersionModel")
+
+	if err := model.CreateIndex(Index{Key: []string{"name"}, Unique: true}); err != nil {
+		t.Fatal("DB: index creation failed", err)
+	}
+
+	existing, duplicate := &TestVersionModel{Name: "Max"}, &TestVersionModel{Name: "Max"}
+
+	model.New(existing)
+	model.New(duplicate)
+
+	if err := existing.Save(); err != nil {
+		t.Fatal("DB: document could not be saved", err)
+	}
+
+	if err := duplicate.Save(); err == nil {
+		t.Fatal("DB: expected duplicate error")
+	} else if _, ok := err.(*DuplicateError); !ok {
+		t.Fatal("DB: expected duplicate error", err)
+	}
+
+	if len(duplicate.Id) > 0 || duplicate.Version != 0 {
+		t.Error("DB: failed insert was not reset", duplicate.Id, duplicate.Version)
+	}
+
+	//the retry is an insert again
+	duplicate.Name = "Moritz"
+
+	if err := duplicate.Save(); err != nil || duplicate.Version != 1 {
+		t.Error("DB: document could not be saved after fixing the duplicate", duplicate.Version, err)
+	}
+}